@@ -0,0 +1,146 @@
+package recall
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// ConfigSettings is the JSON representation of the settings of a Config.
+type ConfigSettings struct {
+	Enabled          bool                 `json:"enabled"`
+	Strategy         string               `json:"strategy,omitempty"`
+	CaptureLevel     string               `json:"capture_level"`
+	Budget           *BudgetSettings      `json:"budget,omitempty"`
+	BodyCaptureLimit int                  `json:"body_capture_limit"`
+	AlwaysFlush      map[string]time.Time `json:"always_flush"`
+}
+
+// BudgetSettings is the JSON representation of a RecallBudget.
+type BudgetSettings struct {
+	PerSecond           float64 `json:"per_second"`
+	Burst               int     `json:"burst"`
+	MaxConcurrentReruns int     `json:"max_concurrent_reruns"`
+}
+
+// ConfigUpdate is the JSON representation of a change of settings ; absent fields are not changed.
+type ConfigUpdate struct {
+	Enabled          *bool           `json:"enabled"`
+	Strategy         *string         `json:"strategy"` // empty resets it
+	CaptureLevel     *string         `json:"capture_level"`
+	Budget           *BudgetSettings `json:"budget"` // a zero per_second removes the budget of the Config
+	BodyCaptureLimit *int            `json:"body_capture_limit"`
+	AlwaysFlush      *struct {
+		Pattern  string `json:"pattern"`
+		Duration string `json:"duration"` // e.g. "5m", "0s" stops it
+	} `json:"always_flush"`
+}
+
+// AdminHandler returns a http.Handler that shows the settings of the Config as JSON on GET
+// and changes them on POST with a ConfigUpdate as JSON body.
+// Make sure the handler is only accessible by operators.
+func AdminHandler(c *Config) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPost:
+			var update ConfigUpdate
+			if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err := c.Update(update); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(c.Settings())
+	})
+}
+
+// Settings returns the current settings.
+func (c *Config) Settings() ConfigSettings {
+	s := ConfigSettings{
+		Enabled:          c.Enabled(),
+		CaptureLevel:     c.CaptureLevel().String(),
+		BodyCaptureLimit: c.BodyCaptureLimit(),
+		AlwaysFlush:      c.AlwaysFlush(),
+	}
+	if strategy, ok := c.Strategy(); ok {
+		s.Strategy = strategy.String()
+	}
+	if b := c.Budget(); b != nil {
+		b.mu.Lock()
+		s.Budget = &BudgetSettings{PerSecond: b.perSecond, Burst: int(b.burst), MaxConcurrentReruns: b.maxReruns}
+		b.mu.Unlock()
+	}
+	return s
+}
+
+// Update changes the settings ; nothing is changed if the update is not valid.
+func (c *Config) Update(u ConfigUpdate) error {
+	strategy := captureStrategy(-1)
+	if u.Strategy != nil {
+		switch *u.Strategy {
+		case "":
+		case RecallOnErrorStrategy.String():
+			strategy = RecallOnErrorStrategy
+		case RecordingStrategy.String():
+			strategy = RecordingStrategy
+		default:
+			return fmt.Errorf("unknown strategy %q", *u.Strategy)
+		}
+	}
+	var level slog.Level
+	if u.CaptureLevel != nil {
+		if err := level.UnmarshalText([]byte(*u.CaptureLevel)); err != nil {
+			return err
+		}
+	}
+	if b := u.Budget; b != nil && b.PerSecond > 0 {
+		if b.Burst < 1 {
+			return fmt.Errorf("budget burst must be at least 1, got %d", b.Burst)
+		}
+		if b.MaxConcurrentReruns < 0 {
+			return fmt.Errorf("budget max_concurrent_reruns must not be negative, got %d", b.MaxConcurrentReruns)
+		}
+	}
+	var flushFor time.Duration
+	if u.AlwaysFlush != nil {
+		d, err := time.ParseDuration(u.AlwaysFlush.Duration)
+		if err != nil {
+			return err
+		}
+		flushFor = d
+	}
+	if u.Enabled != nil {
+		c.SetEnabled(*u.Enabled)
+	}
+	if u.Strategy != nil {
+		c.strategy.Store(int32(strategy))
+	}
+	if u.CaptureLevel != nil {
+		c.SetCaptureLevel(level)
+	}
+	if u.Budget != nil {
+		if u.Budget.PerSecond <= 0 {
+			c.SetBudget(nil)
+		} else {
+			c.SetBudget(NewRecallBudget(u.Budget.PerSecond, u.Budget.Burst, u.Budget.MaxConcurrentReruns))
+		}
+	}
+	if u.BodyCaptureLimit != nil {
+		c.SetBodyCaptureLimit(*u.BodyCaptureLimit)
+	}
+	if u.AlwaysFlush != nil {
+		c.FlushAlways(u.AlwaysFlush.Pattern, flushFor)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package recall
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAdminHandler(t *testing.T) {
+	c := NewConfig()
+	h := AdminHandler(c)
+	body := `{"enabled":false,"strategy":"recording","capture_level":"INFO","budget":{"per_second":5,"burst":10},
+		"body_capture_limit":1024,"always_flush":{"pattern":"/orders/","duration":"5m"}}`
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, httptest.NewRequest("POST", "/", strings.NewReader(body)))
+	if got, want := resp.Code, http.StatusOK; got != want {
+		t.Fatalf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if got, want := c.Enabled(), false; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if got, _ := c.Strategy(); got != RecordingStrategy {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, RecordingStrategy)
+	}
+	if got, want := c.Settings().Strategy, "recording"; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if got, want := c.CaptureLevel(), slog.LevelInfo; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if got, want := c.BodyCaptureLimit(), 1024; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+
+	resp = httptest.NewRecorder()
+	h.ServeHTTP(resp, httptest.NewRequest("GET", "/", nil))
+	var settings ConfigSettings
+	if err := json.NewDecoder(resp.Body).Decode(&settings); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := settings.Budget.Burst, 10; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if _, ok := settings.AlwaysFlush["/orders/"]; !ok {
+		t.Error("expected always flush for /orders/")
+	}
+}
+
+func TestAdminHandlerInvalidUpdate(t *testing.T) {
+	c := NewConfig()
+	resp := httptest.NewRecorder()
+	AdminHandler(c).ServeHTTP(resp, httptest.NewRequest("POST", "/", strings.NewReader(`{"enabled":false,"strategy":"unknown"}`)))
+	if got, want := resp.Code, http.StatusBadRequest; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if got, want := c.Enabled(), true; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	for _, body := range []string{
+		`{"budget":{"per_second":5,"burst":0}}`,
+		`{"budget":{"per_second":5,"burst":10,"max_concurrent_reruns":-1}}`,
+	} {
+		resp = httptest.NewRecorder()
+		AdminHandler(c).ServeHTTP(resp, httptest.NewRequest("POST", "/", strings.NewReader(body)))
+		if got, want := resp.Code, http.StatusBadRequest; got != want {
+			t.Errorf("%s: got [%v] want [%v]", body, got, want)
+		}
+	}
+	if c.Budget() != nil {
+		t.Error("expected no budget")
+	}
+	resp = httptest.NewRecorder()
+	AdminHandler(c).ServeHTTP(resp, httptest.NewRequest("DELETE", "/", nil))
+	if got, want := resp.Code, http.StatusMethodNotAllowed; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
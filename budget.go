@@ -0,0 +1,113 @@
+package recall
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// RecallBudget limits the recalls of all Recallers and RecallHandlers that share it, to protect a system during a failure storm.
+// It allows a number of recalls per second (token bucket) and a maximum number of concurrent reruns.
+// A recall that exceeds the budget is suppressed: recorded records are dropped and functions are not called again.
+// The number of suppressed recalls is logged periodically.
+type RecallBudget struct {
+	mu              sync.Mutex
+	perSecond       float64
+	burst           float64
+	tokens          float64
+	last            time.Time
+	maxReruns       int
+	reruns          int
+	suppressed      int
+	summaryPending  bool
+	summaryInterval time.Duration
+	now             func() time.Time
+}
+
+// NewRecallBudget returns a RecallBudget that allows perSecond recalls with bursts up to burst recalls
+// and at most maxConcurrentReruns reruns by the RecallOnErrorStrategy at the same time (zero means no limit).
+func NewRecallBudget(perSecond float64, burst int, maxConcurrentReruns int) *RecallBudget {
+	return &RecallBudget{
+		perSecond:       perSecond,
+		burst:           float64(burst),
+		tokens:          float64(burst),
+		maxReruns:       maxConcurrentReruns,
+		summaryInterval: time.Minute,
+		now:             time.Now,
+	}
+}
+
+// WithSummaryInterval sets the interval for logging the number of suppressed recalls. Default is one minute.
+func (b *RecallBudget) WithSummaryInterval(d time.Duration) *RecallBudget {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.summaryInterval = d
+	return b
+}
+
+// allow returns true if a recall is within budget. A nil budget allows all.
+func (b *RecallBudget) allow() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.take()
+}
+
+// acquireRerun returns true if a rerun is within budget ; release must be called after the rerun.
+func (b *RecallBudget) acquireRerun() (release func(), ok bool) {
+	if b == nil {
+		return func() {}, true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.maxReruns > 0 && b.reruns >= b.maxReruns {
+		b.suppress()
+		return nil, false
+	}
+	if !b.take() {
+		return nil, false
+	}
+	b.reruns++
+	return func() {
+		b.mu.Lock()
+		b.reruns--
+		b.mu.Unlock()
+	}, true
+}
+
+// take consumes a token if available. Must be called with the lock held.
+func (b *RecallBudget) take() bool {
+	now := b.now()
+	if !b.last.IsZero() {
+		b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.perSecond)
+	}
+	b.last = now
+	if b.tokens < 1 {
+		b.suppress()
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// suppress counts a suppressed recall and schedules the summary. Must be called with the lock held.
+func (b *RecallBudget) suppress() {
+	b.suppressed++
+	if !b.summaryPending {
+		b.summaryPending = true
+		time.AfterFunc(b.summaryInterval, b.logSummary)
+	}
+}
+
+func (b *RecallBudget) logSummary() {
+	b.mu.Lock()
+	n := b.suppressed
+	b.suppressed = 0
+	b.summaryPending = false
+	interval := b.summaryInterval
+	b.mu.Unlock()
+	slog.Warn(fmt.Sprintf("[RECALL] %d recalls suppressed", n), "interval", interval)
+}
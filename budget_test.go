@@ -0,0 +1,138 @@
+package recall
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRecallBudgetTokens(t *testing.T) {
+	now := time.Now()
+	b := NewRecallBudget(1, 2, 0).WithSummaryInterval(time.Hour)
+	b.now = func() time.Time { return now }
+	for i, want := range []bool{true, true, false} {
+		if got := b.allow(); got != want {
+			t.Errorf("%d: got [%[2]v:%[2]T] want [%[3]v:%[3]T]", i, got, want)
+		}
+	}
+	now = now.Add(time.Second)
+	if got, want := b.allow(), true; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if got, want := b.suppressed, 1; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
+
+func TestRecallBudgetConcurrentReruns(t *testing.T) {
+	b := NewRecallBudget(100, 100, 1).WithSummaryInterval(time.Hour)
+	release, ok := b.acquireRerun()
+	if !ok {
+		t.Fatal("expected first rerun")
+	}
+	if _, ok := b.acquireRerun(); ok {
+		t.Error("expected second rerun to be suppressed")
+	}
+	release()
+	if _, ok := b.acquireRerun(); !ok {
+		t.Error("expected rerun after release")
+	}
+}
+
+func TestRecallBudgetSummary(t *testing.T) {
+	rec := new(recording)
+	old := slog.Default()
+	slog.SetDefault(slog.New(rec))
+	defer slog.SetDefault(old)
+	b := NewRecallBudget(0, 0, 0).WithSummaryInterval(time.Hour)
+	b.allow()
+	b.allow()
+	b.logSummary()
+	if got, want := len(rec.records), 1; got != want {
+		t.Fatalf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if got, want := rec.records[0].Message, "[RECALL] 2 recalls suppressed"; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
+
+func TestRecallerBudgetSkipsRerun(t *testing.T) {
+	m := new(countingMetrics)
+	b := NewRecallBudget(0, 1, 0).WithSummaryInterval(time.Hour)
+	calls := 0
+	r := New(context.Background()).WithBudget(b).WithMetrics(m)
+	f := func(ctx context.Context) error {
+		calls++
+		return willError(ctx)
+	}
+	r.Call(f)
+	r.Call(f)
+	if got, want := calls, 3; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if got, want := m.get("recalls_suppressed recall_on_error "), int64(1); got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
+
+func TestRecallHandlerBudgetSkipsReplay(t *testing.T) {
+	rec := new(recording)
+	old := slog.Default()
+	slog.SetDefault(slog.New(rec))
+	defer slog.SetDefault(old)
+	b := NewRecallBudget(0, 1, 0).WithSummaryInterval(time.Hour)
+	h := NewRecallHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Slog(r.Context()).Debug("trail")
+		w.WriteHeader(http.StatusInternalServerError)
+	})).WithBudget(b)
+	for range 2 {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}
+	// first: trail + failure line, second: failure line only
+	if got, want := len(rec.records), 3; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
+
+func TestRecallerBudgetSkipsErrorRecordFlush(t *testing.T) {
+	sink := new(MemorySink)
+	m := new(countingMetrics)
+	b := NewRecallBudget(0, 0, 0).WithSummaryInterval(time.Hour)
+	r := New(context.Background()).WithCaptureStrategy(RecordingStrategy).WithBudget(b).WithSink(sink).WithMetrics(m)
+	r.Call(func(ctx context.Context) error {
+		Slog(ctx).Debug("trail")
+		Slog(ctx).Error("failed")
+		return nil
+	})
+	if got, want := len(sink.Sessions()), 0; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if got, want := m.get("recalls_suppressed recording "), int64(1); got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
+
+func TestRecallerBudgetDecidesOncePerFailure(t *testing.T) {
+	sink := new(MemorySink)
+	m := new(countingMetrics)
+	b := NewRecallBudget(0, 1, 0).WithSummaryInterval(time.Hour)
+	r := New(context.Background()).WithCaptureStrategy(RecordingStrategy).WithBudget(b).WithSink(sink).WithMetrics(m)
+	r.Call(func(ctx context.Context) error {
+		Slog(ctx).Debug("trail")
+		Slog(ctx).Error("failed")
+		return errors.New("failed")
+	})
+	if got, want := len(sink.Sessions()), 1; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if got, want := m.get("recalls recording "), int64(1); got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if got, want := m.get("recalls_suppressed recording "), int64(0); got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
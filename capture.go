@@ -0,0 +1,177 @@
+package recall
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+)
+
+type captureMode int
+
+const (
+	// RecordsCaptureMode keeps the log records as is ; attribute values are resolved when the records are written.
+	RecordsCaptureMode captureMode = iota
+	// SnapshotCaptureMode resolves slog.LogValuer values and replaces values of other (any) kinds by a copy decoded from their JSON encoding
+	// when a record is captured, such that later changes to the values are not visible when the records are written.
+	// Errors are replaced by their message and values that cannot be encoded by their string representation.
+	SnapshotCaptureMode
+	// EncodedCaptureMode encodes the log records as JSON when captured, which is cheaper to retain.
+	// The records are decoded when written ; numbers, strings, booleans, time and nested groups are preserved.
+	EncodedCaptureMode
+)
+
+// snapshot returns a copy of the record with all attribute values resolved.
+func snapshot(record slog.Record) slog.Record {
+	clone := slog.NewRecord(record.Time, record.Level, record.Message, record.PC)
+	record.Attrs(func(a slog.Attr) bool {
+		clone.AddAttrs(snapshotAttr(a))
+		return true
+	})
+	return clone
+}
+
+func snapshotAttr(a slog.Attr) slog.Attr {
+	v := a.Value.Resolve()
+	switch v.Kind() {
+	case slog.KindGroup:
+		group := v.Group()
+		attrs := make([]slog.Attr, len(group))
+		for i, each := range group {
+			attrs[i] = snapshotAttr(each)
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(attrs...)}
+	case slog.KindAny:
+		return slog.Attr{Key: a.Key, Value: snapshotAny(v.Any())}
+	}
+	return slog.Attr{Key: a.Key, Value: v}
+}
+
+// snapshotAny returns a copy of the value by encoding and decoding it as JSON, like a slog.JSONHandler would write it.
+func snapshotAny(v any) slog.Value {
+	if err, ok := v.(error); ok {
+		return slog.StringValue(err.Error())
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return slog.StringValue(fmt.Sprint(v))
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	// keep large numbers as is
+	dec.UseNumber()
+	var copied any
+	if err := dec.Decode(&copied); err != nil {
+		return slog.StringValue(fmt.Sprint(v))
+	}
+	return slog.AnyValue(copied)
+}
+
+// decodeRecords returns the records from JSON lines as written by a slog.JSONHandler, with their program counters.
+func decodeRecords(data []byte, pcs []uintptr) (list []slog.Record, err error) {
+	for _, line := range bytes.Split(bytes.TrimSpace(data), []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		record, err := decodeRecord(line)
+		if err != nil {
+			return list, err
+		}
+		if i := len(list); i < len(pcs) {
+			record.PC = pcs[i]
+		}
+		list = append(list, record)
+	}
+	return list, nil
+}
+
+// builtinKeys are the keys that a slog.JSONHandler writes first, in this order, if present.
+var builtinKeys = []string{slog.TimeKey, slog.LevelKey, slog.MessageKey}
+
+// decodeRecord returns the record from a JSON object, keeping the order of the attributes.
+// Only the leading time, level and message members are decoded as such ; attributes with the same keys are kept.
+func decodeRecord(line []byte) (slog.Record, error) {
+	dec := json.NewDecoder(bytes.NewReader(line))
+	dec.UseNumber()
+	if _, err := dec.Token(); err != nil { // {
+		return slog.Record{}, err
+	}
+	attrs, err := decodeAttrs(dec)
+	if err != nil {
+		return slog.Record{}, err
+	}
+	var record slog.Record
+	next := 0 // index in builtinKeys
+	for len(attrs) > 0 && next < len(builtinKeys) {
+		i := slices.Index(builtinKeys[next:], attrs[0].Key)
+		if i < 0 {
+			break
+		}
+		next += i + 1
+		switch value := attrs[0].Value.String(); attrs[0].Key {
+		case slog.TimeKey:
+			record.Time, _ = time.Parse(time.RFC3339Nano, value)
+		case slog.LevelKey:
+			_ = record.Level.UnmarshalText([]byte(value))
+		case slog.MessageKey:
+			record.Message = value
+		}
+		attrs = attrs[1:]
+	}
+	record.AddAttrs(attrs...)
+	return record, nil
+}
+
+// decodeAttrs reads the members of a JSON object until its closing delimiter.
+func decodeAttrs(dec *json.Decoder) (attrs []slog.Attr, err error) {
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return attrs, err
+		}
+		key, _ := token.(string)
+		value, err := decodeValue(dec)
+		if err != nil {
+			return attrs, err
+		}
+		attrs = append(attrs, slog.Attr{Key: key, Value: value})
+	}
+	_, err = dec.Token() // }
+	return attrs, err
+}
+
+func decodeValue(dec *json.Decoder) (slog.Value, error) {
+	token, err := dec.Token()
+	if err != nil {
+		return slog.Value{}, err
+	}
+	switch t := token.(type) {
+	case json.Delim:
+		if t == '{' {
+			attrs, err := decodeAttrs(dec)
+			return slog.GroupValue(attrs...), err
+		}
+		var list []any
+		for dec.More() {
+			var each any
+			if err := dec.Decode(&each); err != nil {
+				return slog.Value{}, err
+			}
+			list = append(list, each)
+		}
+		_, err := dec.Token() // ]
+		return slog.AnyValue(list), err
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return slog.Int64Value(i), nil
+		}
+		f, _ := t.Float64()
+		return slog.Float64Value(f), nil
+	case string:
+		return slog.StringValue(t), nil
+	case bool:
+		return slog.BoolValue(t), nil
+	}
+	return slog.AnyValue(nil), nil
+}
@@ -0,0 +1,134 @@
+package recall
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+type mutable struct{ State string }
+
+type lazyValue struct{ calls *int }
+
+func (l lazyValue) LogValue() slog.Value {
+	*l.calls++
+	return slog.StringValue("resolved")
+}
+
+func TestSnapshotCaptureMode(t *testing.T) {
+	sink := new(MemorySink)
+	calls := 0
+	m := &mutable{State: "before"}
+	r := New(context.Background()).WithCaptureStrategy(RecordingStrategy).WithCaptureMode(SnapshotCaptureMode).WithSink(sink)
+	r.Call(func(ctx context.Context) error {
+		Slog(ctx).Debug("snap", "m", m, "lazy", lazyValue{&calls}, slog.Group("g", "n", 1))
+		m.State = "after"
+		return errors.New("fail")
+	})
+	if got, want := calls, 1; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	attrs := attrsFrom(sink.Sessions()[0].Records[0])
+	if got, want := fmt.Sprint(attrs[0].Value.Any()), "map[State:before]"; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if got, want := attrs[1].Value.String(), "resolved"; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if got, want := attrs[2].Value.Group()[0].Value.Int64(), int64(1); got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
+
+func TestEncodedCaptureMode(t *testing.T) {
+	sink := new(MemorySink)
+	r := New(context.Background()).WithCaptureStrategy(RecordingStrategy).WithCaptureMode(EncodedCaptureMode).WithSink(sink)
+	r.Call(func(ctx context.Context) error {
+		Slog(ctx).Debug("first", "s", "v", "i", 42, "f", 1.5, "b", true, slog.Group("g", "n", 1))
+		Slog(ctx).Debug("second", "d", time.Second)
+		return errors.New("fail")
+	})
+	records := sink.Sessions()[0].Records
+	if got, want := len(records), 2; got != want {
+		t.Fatalf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	first := records[0]
+	if got, want := first.Message, "first"; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if got, want := first.Level, slog.LevelDebug; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if first.Time.IsZero() {
+		t.Error("expected time")
+	}
+	attrs := attrsFrom(first)
+	if got, want := len(attrs), 5; got != want {
+		t.Fatalf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if got, want := attrs[1].Value.Int64(), int64(42); got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if got, want := attrs[2].Value.Float64(), 1.5; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if got, want := attrs[3].Value.Bool(), true; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if got, want := attrs[4].Value.Group()[0].Key, "n"; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
+
+func TestEncodedCaptureModeDropped(t *testing.T) {
+	m := new(countingMetrics)
+	r := New(context.Background()).WithCaptureStrategy(RecordingStrategy).WithCaptureMode(EncodedCaptureMode).WithMetrics(m)
+	r.Call(func(ctx context.Context) error {
+		Slog(ctx).Debug("dropped")
+		return nil
+	})
+	if got, want := m.get("records_dropped recording "), int64(1); got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
+
+func TestEncodedCaptureModeKeepsBuiltinKeyAttrs(t *testing.T) {
+	sink := new(MemorySink)
+	r := New(context.Background()).WithCaptureStrategy(RecordingStrategy).WithCaptureMode(EncodedCaptureMode).WithSink(sink)
+	r.Call(func(ctx context.Context) error {
+		Slog(ctx).Debug("p", "msg", "shadow", "level", "INFO", "time", "x")
+		return errors.New("fail")
+	})
+	record := sink.Sessions()[0].Records[0]
+	if got, want := record.Message, "p"; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if got, want := record.Level, slog.LevelDebug; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if record.Time.IsZero() {
+		t.Error("expected time")
+	}
+	if record.PC == 0 {
+		t.Error("expected program counter")
+	}
+	if got, want := len(attrsFrom(record)), 3; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
+
+func TestSnapshotCaptureModeStruct(t *testing.T) {
+	type point struct{ X, Y int }
+	got := snapshotAttr(slog.Any("p", point{1, 2}))
+	data, _ := json.Marshal(got.Value.Any())
+	if got, want := string(data), `{"X":1,"Y":2}`; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if got, want := snapshotAttr(slog.Any("err", errors.New("failed"))).Value.String(), "failed"; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
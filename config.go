@@ -0,0 +1,155 @@
+package recall
+
+import (
+	"log/slog"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Config holds settings that can be changed at runtime, e.g. by an AdminHandler, and that are shared by all
+// Recallers and RecallHandlers created with it. Its settings override those of the Recaller or RecallHandler,
+// except for the strategy, budget and body capture limit if these are not set.
+type Config struct {
+	enabled          atomic.Bool
+	strategy         atomic.Int32
+	captureLevel     atomic.Int64
+	budget           atomic.Pointer[RecallBudget]
+	bodyCaptureLimit atomic.Int64
+	mu               sync.Mutex                           // serializes changes of alwaysFlush
+	alwaysFlush      atomic.Pointer[map[string]time.Time] // route pattern or path prefix -> until ; replaced on change, nil if never set
+}
+
+// NewConfig returns a Config that is enabled, does not change the strategy, captures records from Debug level,
+// has no budget and does not change the body capture limit.
+func NewConfig() *Config {
+	c := new(Config)
+	c.enabled.Store(true)
+	c.strategy.Store(-1)
+	c.captureLevel.Store(int64(slog.LevelDebug))
+	c.bodyCaptureLimit.Store(-1)
+	return c
+}
+
+// Enabled returns false if recalls are disabled ; functions and requests are then handled without recording or recall.
+// Panics are still recovered if enabled on the Recaller or RecallHandler.
+func (c *Config) Enabled() bool { return c.enabled.Load() }
+
+// SetEnabled enables or disables recalls.
+func (c *Config) SetEnabled(enabled bool) { c.enabled.Store(enabled) }
+
+// Strategy returns the capture strategy used by Recallers ; ok is false if not set and the strategy of each Recaller is used.
+// RecallHandlers always use the RecordingStrategy.
+func (c *Config) Strategy() (s captureStrategy, ok bool) {
+	v := c.strategy.Load()
+	return captureStrategy(v), v >= 0
+}
+
+// SetStrategy sets the capture strategy used by Recallers.
+func (c *Config) SetStrategy(s captureStrategy) { c.strategy.Store(int32(s)) }
+
+// ResetStrategy makes Recallers use their own capture strategy.
+func (c *Config) ResetStrategy() { c.strategy.Store(-1) }
+
+// CaptureLevel returns the lowest level of log records that are recorded ; records below it are discarded.
+func (c *Config) CaptureLevel() slog.Level { return slog.Level(c.captureLevel.Load()) }
+
+// SetCaptureLevel sets the lowest level of log records that are recorded.
+func (c *Config) SetCaptureLevel(level slog.Level) { c.captureLevel.Store(int64(level)) }
+
+// Budget returns the RecallBudget or nil if not set.
+func (c *Config) Budget() *RecallBudget { return c.budget.Load() }
+
+// SetBudget sets the RecallBudget ; nil means the budget of each Recaller or RecallHandler, if any, is used.
+func (c *Config) SetBudget(b *RecallBudget) { c.budget.Store(b) }
+
+// BodyCaptureLimit returns the maximum number of request body bytes captured by RecallHandlers or -1 if not changed.
+func (c *Config) BodyCaptureLimit() int { return int(c.bodyCaptureLimit.Load()) }
+
+// SetBodyCaptureLimit sets the maximum number of request body bytes captured by RecallHandlers ; -1 means not changed.
+// It can only lower the limit set on a handler or route.
+func (c *Config) SetBodyCaptureLimit(maxBytes int) { c.bodyCaptureLimit.Store(int64(maxBytes)) }
+
+// FlushAlways makes RecallHandlers write the recorded log records of all requests, also successful ones,
+// that are handled by a route pattern (e.g. "GET /orders/{id}") or match a path prefix (e.g. "/orders/") for the given duration.
+// A zero duration stops it.
+func (c *Config) FlushAlways(pattern string, d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// copy the active windows such that readers need no lock
+	always := c.AlwaysFlush()
+	if d <= 0 {
+		delete(always, pattern)
+	} else {
+		always[pattern] = time.Now().Add(d)
+	}
+	c.alwaysFlush.Store(&always)
+}
+
+// AlwaysFlush returns the route patterns and path prefixes for which log records are always written, with the time until.
+func (c *Config) AlwaysFlush() map[string]time.Time {
+	now := time.Now()
+	active := map[string]time.Time{}
+	always := c.alwaysFlush.Load()
+	if always == nil {
+		return active
+	}
+	for pattern, until := range *always {
+		if now.Before(until) {
+			active[pattern] = until
+		}
+	}
+	return active
+}
+
+// flushAlways returns true if the log records of the request must be written.
+func (c *Config) flushAlways(r *http.Request, route string) bool {
+	always := c.alwaysFlush.Load()
+	if always == nil {
+		return false
+	}
+	now := time.Now()
+	for pattern, until := range *always {
+		if now.After(until) {
+			continue
+		}
+		if route != "" && pattern == route {
+			return true
+		}
+		if (routeConfig{pattern: pattern}).isPrefix() && strings.HasPrefix(r.URL.Path, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// applyTo returns the Recaller with the current settings.
+func (c *Config) applyTo(r Recaller) Recaller {
+	if s, ok := c.Strategy(); ok {
+		r.captureStrategy = s
+	}
+	r.captureLevel = c.CaptureLevel()
+	if b := c.Budget(); b != nil {
+		r.budget = b
+	}
+	return r
+}
+
+// applyToHandler returns the RecallHandler with the current settings.
+func (c *Config) applyToHandler(h RecallHandler) RecallHandler {
+	h.captureLevel = c.CaptureLevel()
+	if b := c.Budget(); b != nil {
+		h.budget = b
+	}
+	// only lower the limit such that routes without body capture keep it disabled
+	if limit := c.BodyCaptureLimit(); limit >= 0 {
+		h.bufferCapacity = min(h.bufferCapacity, limit)
+	}
+	return h
+}
+
+// noCaptureLevel is the capture level when not set by a Config ; all records are recorded.
+const noCaptureLevel = slog.Level(math.MinInt)
@@ -0,0 +1,143 @@
+package recall
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConfigDisabled(t *testing.T) {
+	c := NewConfig()
+	c.SetEnabled(false)
+	calls := 0
+	New(context.Background()).WithConfig(c).Call(func(ctx context.Context) error {
+		calls++
+		return willError(ctx)
+	})
+	if got, want := calls, 1; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
+
+func TestConfigStrategyAndCaptureLevel(t *testing.T) {
+	c := NewConfig()
+	c.SetStrategy(RecordingStrategy)
+	c.SetCaptureLevel(slog.LevelDebug)
+	sink := new(MemorySink)
+	calls := 0
+	New(context.Background()).WithConfig(c).WithSink(sink).Call(func(ctx context.Context) error {
+		calls++
+		Slog(ctx).Log(ctx, slog.LevelDebug-4, "trace")
+		return willError(ctx)
+	})
+	if got, want := calls, 1; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	records := sink.Sessions()[0].Records
+	if got, want := len(records), 1; got != want {
+		t.Fatalf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if got, want := records[0].Message, "will error"; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
+
+func TestConfigBodyCaptureLimit(t *testing.T) {
+	rec := new(recording)
+	old := slog.Default()
+	slog.SetDefault(slog.New(rec))
+	defer slog.SetDefault(old)
+	c := NewConfig()
+	c.SetBodyCaptureLimit(4)
+	h := NewRecallHandler(erroringHandler{}).WithConfig(c)
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/", strings.NewReader("0123456789")))
+	last := rec.records[len(rec.records)-1]
+	var payload string
+	last.Attrs(func(a slog.Attr) bool {
+		if a.Key == "payload" {
+			payload = a.Value.String()
+		}
+		return true
+	})
+	if got, want := payload, "0123..(4 of 10)"; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
+
+func TestConfigFlushAlways(t *testing.T) {
+	sink := new(MemorySink)
+	c := NewConfig()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /orders/{id}", func(w http.ResponseWriter, r *http.Request) {
+		Slog(r.Context()).Debug("order")
+	})
+	h := NewRecallHandler(mux).WithConfig(c).WithSink(sink)
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/orders/1", nil))
+	if got, want := len(sink.Sessions()), 0; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	c.FlushAlways("GET /orders/{id}", time.Minute)
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/orders/2", nil))
+	if got, want := len(sink.Sessions()), 1; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	c.FlushAlways("GET /orders/{id}", 0)
+	c.FlushAlways("/orders/", time.Minute)
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/orders/3", nil))
+	if got, want := len(sink.Sessions()), 2; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
+
+func TestConfigKeepsRecallerStrategy(t *testing.T) {
+	calls := 0
+	New(context.Background()).WithCaptureStrategy(RecordingStrategy).WithConfig(NewConfig()).Call(func(ctx context.Context) error {
+		calls++
+		return willError(ctx)
+	})
+	if got, want := calls, 1; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
+
+func TestConfigDisabledRecoversPanic(t *testing.T) {
+	c := NewConfig()
+	c.SetEnabled(false)
+	if err := New(context.Background()).WithConfig(c).Call(willPanic); err == nil {
+		t.Error("expected error from recovered panic")
+	}
+	h := NewRecallHandler(erroringHandler{dopanic: true}).WithConfig(c)
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, httptest.NewRequest("GET", "/", nil))
+	if got, want := resp.Code, http.StatusInternalServerError; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
+
+func TestConfigBodyCaptureLimitKeepsRouteDisabled(t *testing.T) {
+	rec := new(recording)
+	old := slog.Default()
+	slog.SetDefault(slog.New(rec))
+	defer slog.SetDefault(old)
+	c := NewConfig()
+	c.SetBodyCaptureLimit(4)
+	h := NewRecallHandler(erroringHandler{}).WithRoute("/upload/", func(rh RecallHandler) RecallHandler {
+		return rh.WithRequestBodyCapture(0)
+	}).WithConfig(c)
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/upload/1", strings.NewReader("0123456789")))
+	last := rec.records[len(rec.records)-1]
+	var payload string
+	last.Attrs(func(a slog.Attr) bool {
+		if a.Key == "payload" {
+			payload = a.Value.String()
+		}
+		return true
+	})
+	if strings.HasPrefix(payload, "0123") {
+		t.Errorf("unexpected payload %q", payload)
+	}
+}
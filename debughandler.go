@@ -10,12 +10,16 @@ import (
 type debugHandler struct {
 	slog.Handler
 	messageFormat string
+	redactor      *Redactor
 }
 
 func (d debugHandler) Enabled(ctx context.Context, level slog.Level) bool {
 	return level >= slog.LevelDebug
 }
 func (d debugHandler) Handle(ctx context.Context, rec slog.Record) error {
+	if d.redactor != nil {
+		rec = d.redactor.redactRecord(rec)
+	}
 	// mark the message as a recall
 	rec.Message = fmt.Sprintf(d.messageFormat, rec.Message)
 	if rec.Level == slog.LevelDebug {
@@ -29,3 +33,19 @@ func (d debugHandler) Handle(ctx context.Context, rec slog.Record) error {
 	}
 	return d.Handler.Handle(ctx, rec)
 }
+
+func (d debugHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if d.redactor != nil {
+		redacted := make([]slog.Attr, len(attrs))
+		for i, each := range attrs {
+			redacted[i] = d.redactor.RedactAttr(each)
+		}
+		attrs = redacted
+	}
+	d.Handler = d.Handler.WithAttrs(attrs)
+	return d
+}
+func (d debugHandler) WithGroup(name string) slog.Handler {
+	d.Handler = d.Handler.WithGroup(name)
+	return d
+}
@@ -0,0 +1,120 @@
+package recall
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Deduplicator limits the recalls of identical recurring failures of all Recallers and RecallHandlers that share it.
+// Failures are identified by a fingerprint ; only the first occurrences of a fingerprint within a time window are recalled.
+// The number of skipped occurrences is logged at the end of the window.
+type Deduplicator struct {
+	mu        sync.Mutex
+	firstK    int
+	window    time.Duration
+	keyFunc   func(info FailureInfo) string
+	windows   map[string]*dedupWindow
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type dedupWindow struct {
+	start time.Time
+	count int
+}
+
+// NewDeduplicator returns a Deduplicator that recalls the first firstK occurrences of a failure per window.
+// The default fingerprint of a failure is FailureFingerprint.
+func NewDeduplicator(firstK int, window time.Duration) *Deduplicator {
+	return &Deduplicator{
+		firstK:  firstK,
+		window:  window,
+		keyFunc: FailureFingerprint,
+		windows: map[string]*dedupWindow{},
+		now:     time.Now,
+	}
+}
+
+// WithKeyFunc sets the function that computes the fingerprint of a failure.
+func (d *Deduplicator) WithKeyFunc(keyFunc func(info FailureInfo) string) *Deduplicator {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.keyFunc = keyFunc
+	return d
+}
+
+// allow returns true if the failure must be recalled. A nil deduplicator allows all.
+func (d *Deduplicator) allow(info FailureInfo) bool {
+	if d == nil {
+		return true
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	key := d.keyFunc(info)
+	now := d.now()
+	d.sweep(now)
+	w, ok := d.windows[key]
+	if !ok || now.Sub(w.start) >= d.window {
+		w = &dedupWindow{start: now}
+		d.windows[key] = w
+	}
+	w.count++
+	if w.count <= d.firstK {
+		return true
+	}
+	if w.count == d.firstK+1 {
+		time.AfterFunc(d.window-now.Sub(w.start), func() { d.logSummary(key, w) })
+	}
+	return false
+}
+
+// sweep removes the expired windows without skipped occurrences. Must be called with the lock held.
+func (d *Deduplicator) sweep(now time.Time) {
+	if now.Sub(d.lastSweep) < d.window {
+		return
+	}
+	d.lastSweep = now
+	for key, w := range d.windows {
+		if w.count <= d.firstK && now.Sub(w.start) >= d.window {
+			delete(d.windows, key)
+		}
+	}
+}
+
+func (d *Deduplicator) logSummary(key string, w *dedupWindow) {
+	d.mu.Lock()
+	skipped := w.count - d.firstK
+	if d.windows[key] == w {
+		delete(d.windows, key)
+	}
+	window := d.window
+	d.mu.Unlock()
+	slog.Warn(fmt.Sprintf("[RECALL] %d recalls skipped for recurring failure", skipped), "fingerprint", key, "window", window)
+}
+
+var numbers = regexp.MustCompile(`[0-9]+`)
+
+// FailureFingerprint returns a key for a failure composed of the request method and route pattern (or path),
+// the status code and the type and message of the error or recovered panic.
+// Numbers in the message are replaced by # such that messages with different identifiers have the same fingerprint.
+func FailureFingerprint(info FailureInfo) string {
+	key := ""
+	if info.Request != nil {
+		route := info.Request.Pattern
+		if route == "" {
+			route = info.Request.Method + " " + info.Request.URL.Path
+		}
+		key = route + " " + strconv.Itoa(info.StatusCode)
+	}
+	if info.Err != nil {
+		key += fmt.Sprintf(" %T %s", info.Err, numbers.ReplaceAllString(info.Err.Error(), "#"))
+	}
+	if info.Recovered != nil {
+		key += fmt.Sprintf(" panic %T %s", info.Recovered, numbers.ReplaceAllString(fmt.Sprint(info.Recovered), "#"))
+	}
+	return key
+}
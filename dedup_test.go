@@ -0,0 +1,102 @@
+package recall
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDeduplicatorWindow(t *testing.T) {
+	now := time.Now()
+	d := NewDeduplicator(2, time.Minute)
+	d.now = func() time.Time { return now }
+	info := FailureInfo{Err: errors.New("boom")}
+	for i, want := range []bool{true, true, false, false} {
+		if got := d.allow(info); got != want {
+			t.Errorf("%d: got [%[2]v:%[2]T] want [%[3]v:%[3]T]", i, got, want)
+		}
+	}
+	if got, want := d.allow(FailureInfo{Err: errors.New("other")}), true; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	now = now.Add(time.Minute)
+	if got, want := d.allow(info), true; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
+
+func TestDeduplicatorSummary(t *testing.T) {
+	rec := new(recording)
+	old := slog.Default()
+	slog.SetDefault(slog.New(rec))
+	defer slog.SetDefault(old)
+	d := NewDeduplicator(1, time.Hour)
+	info := FailureInfo{Err: errors.New("boom")}
+	d.allow(info)
+	d.allow(info)
+	d.allow(info)
+	d.logSummary(FailureFingerprint(info), d.windows[FailureFingerprint(info)])
+	if got, want := len(rec.records), 1; got != want {
+		t.Fatalf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if got, want := rec.records[0].Message, "[RECALL] 2 recalls skipped for recurring failure"; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if got, want := len(d.windows), 0; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
+
+func TestFailureFingerprint(t *testing.T) {
+	r := httptest.NewRequest("GET", "/users/42", nil)
+	a := FailureFingerprint(FailureInfo{Request: r, StatusCode: 500, Err: fmt.Errorf("user %d not found", 42)})
+	b := FailureFingerprint(FailureInfo{Request: r, StatusCode: 500, Err: fmt.Errorf("user %d not found", 7)})
+	if got, want := a, b; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if got, want := a, "GET /users/42 500 *errors.errorString user # not found"; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
+
+func TestRecallerDeduplicatorSkipsRerun(t *testing.T) {
+	m := new(countingMetrics)
+	d := NewDeduplicator(1, time.Hour)
+	calls := 0
+	r := New(context.Background()).WithDeduplicator(d).WithMetrics(m)
+	f := func(ctx context.Context) error {
+		calls++
+		return willError(ctx)
+	}
+	r.Call(f)
+	r.Call(f)
+	if got, want := calls, 3; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if got, want := m.get("recalls_deduplicated recall_on_error "), int64(1); got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
+
+func TestRecallHandlerDeduplicatorSkipsReplay(t *testing.T) {
+	rec := new(recording)
+	old := slog.Default()
+	slog.SetDefault(slog.New(rec))
+	defer slog.SetDefault(old)
+	h := NewRecallHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Slog(r.Context()).Debug("trail")
+		w.WriteHeader(http.StatusInternalServerError)
+	})).WithDeduplicator(NewDeduplicator(1, time.Hour))
+	for range 2 {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}
+	// first: trail + failure line, second: failure line only
+	if got, want := len(rec.records), 3; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
@@ -0,0 +1,42 @@
+package recall
+
+import (
+	"net/http"
+	"slices"
+	"time"
+)
+
+// RequestOutcome describes the result of handling an HTTP request by a RecallHandler.
+type RequestOutcome struct {
+	// Request is the request as passed to the next handler.
+	// Its Pattern field is set if the request was routed by a http.ServeMux.
+	Request *http.Request
+	// StatusCode is the status sent to the client.
+	StatusCode int
+	// Latency is the time it took to handle the request.
+	Latency time.Duration
+	// Header holds the response headers.
+	Header http.Header
+}
+
+// FailurePolicy decides whether the outcome of a request is a failure.
+// If it returns true then the recorded Debug logs and the request details are written.
+type FailurePolicy func(o RequestOutcome) bool
+
+// ServerErrorsOnly is the default FailurePolicy ; it reports a failure for status codes 5xx.
+func ServerErrorsOnly(o RequestOutcome) bool {
+	return o.StatusCode >= http.StatusInternalServerError
+}
+
+// AllErrors is a FailurePolicy that reports a failure for client (4xx) and server (5xx) errors.
+func AllErrors(o RequestOutcome) bool {
+	return o.StatusCode >= http.StatusBadRequest
+}
+
+// ErrorsExcept returns a FailurePolicy that reports a failure for client and server errors
+// except for the given status codes, e.g. ErrorsExcept(404, 401).
+func ErrorsExcept(statusCodes ...int) FailurePolicy {
+	return func(o RequestOutcome) bool {
+		return AllErrors(o) && !slices.Contains(statusCodes, o.StatusCode)
+	}
+}
@@ -0,0 +1,64 @@
+package recall
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFailurePolicyPresets(t *testing.T) {
+	for _, each := range []struct {
+		policy FailurePolicy
+		status int
+		fail   bool
+	}{
+		{ServerErrorsOnly, 200, false},
+		{ServerErrorsOnly, 404, false},
+		{ServerErrorsOnly, 503, true},
+		{AllErrors, 302, false},
+		{AllErrors, 404, true},
+		{AllErrors, 500, true},
+		{ErrorsExcept(404, 401), 404, false},
+		{ErrorsExcept(404, 401), 401, false},
+		{ErrorsExcept(404, 401), 400, true},
+		{ErrorsExcept(404, 401), 200, false},
+	} {
+		if got, want := each.policy(RequestOutcome{StatusCode: each.status}), each.fail; got != want {
+			t.Errorf("status %d: got [%v] want [%v]", each.status, got, want)
+		}
+	}
+}
+
+func TestRecallHandlerFailurePolicyRoutePattern(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /items/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	var outcome RequestOutcome
+	h := NewRecallHandler(mux).WithFailurePolicy(func(o RequestOutcome) bool {
+		outcome = o
+		return AllErrors(o)
+	})
+	req, _ := http.NewRequest("GET", "/items/42", nil)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if got, want := outcome.Request.Pattern, "GET /items/{id}"; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if got, want := outcome.StatusCode, http.StatusNotFound; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
+
+func TestRecallHandlerImplicitOK(t *testing.T) {
+	var status int
+	h := NewRecallHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).
+		WithFailurePolicy(func(o RequestOutcome) bool {
+			status = o.StatusCode
+			return false
+		})
+	req, _ := http.NewRequest("GET", "/", nil)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if got, want := status, http.StatusOK; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
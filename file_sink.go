@@ -0,0 +1,195 @@
+package recall
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	sessionFilePrefix = "recall-"
+	sessionFileSuffix = ".json"
+)
+
+// FileSink writes the records and the failure information of each failure as a JSON document to a new file in a directory.
+// Files are written atomically and old files are removed based on their age, their count and the total size of the directory.
+// A FileSink must be created with NewFileSink ; its copies share the file sequence.
+type FileSink struct {
+	dir          string
+	maxAge       time.Duration
+	maxFiles     int
+	maxTotalSize int64
+	mu           *sync.Mutex
+	sequence     *int
+}
+
+// NewFileSink returns a FileSink that writes to the directory, which is created if needed.
+// By default, no files are removed.
+func NewFileSink(dir string) FileSink {
+	return FileSink{dir: dir, mu: new(sync.Mutex), sequence: new(int)}
+}
+
+// WithMaxAge sets the age after which files are removed.
+func (s FileSink) WithMaxAge(age time.Duration) FileSink {
+	s.maxAge = age
+	return s
+}
+
+// WithMaxFiles sets the maximum number of files to keep ; the oldest files are removed first.
+func (s FileSink) WithMaxFiles(count int) FileSink {
+	s.maxFiles = count
+	return s
+}
+
+// WithMaxTotalSize sets the maximum number of bytes of all files to keep ; the oldest files are removed first.
+func (s FileSink) WithMaxTotalSize(bytes int64) FileSink {
+	s.maxTotalSize = bytes
+	return s
+}
+
+// sessionDocument is the JSON document of a failure with its records.
+type sessionDocument struct {
+	Time    time.Time         `json:"time"`
+	Failure map[string]any    `json:"failure"`
+	Records []json.RawMessage `json:"records"`
+}
+
+func newSessionDocument(info FailureInfo, records []slog.Record) (sessionDocument, error) {
+	doc := sessionDocument{
+		Time:    info.Time,
+		Failure: attrsMap(append(info.attrs(), info.Attrs...)),
+		Records: make([]json.RawMessage, 0, len(records)),
+	}
+	buf := new(bytes.Buffer)
+	enc := slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug})
+	for _, each := range records {
+		buf.Reset()
+		if err := enc.Handle(context.Background(), each); err != nil {
+			return doc, err
+		}
+		doc.Records = append(doc.Records, json.RawMessage(bytes.TrimSpace(buf.Bytes())))
+	}
+	return doc, nil
+}
+
+// attrsMap returns the attributes as a map that can be encoded as JSON.
+func attrsMap(attrs []slog.Attr) map[string]any {
+	m := make(map[string]any, len(attrs))
+	for _, each := range attrs {
+		v := each.Value.Resolve()
+		switch v.Kind() {
+		case slog.KindGroup:
+			m[each.Key] = attrsMap(v.Group())
+		case slog.KindAny:
+			if err, ok := v.Any().(error); ok {
+				m[each.Key] = err.Error()
+			} else {
+				m[each.Key] = v.Any()
+			}
+		default:
+			m[each.Key] = v.Any()
+		}
+	}
+	return m
+}
+
+// Flush implements Sink
+func (s FileSink) Flush(ctx context.Context, info FailureInfo, records []slog.Record) error {
+	if s.mu == nil {
+		return errors.New("FileSink must be created with NewFileSink")
+	}
+	doc, err := newSessionDocument(info, records)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return err
+	}
+	*s.sequence++
+	name := fmt.Sprintf("%s%s-%06d%s", sessionFilePrefix, info.Time.UTC().Format("20060102T150405.000000000Z"), *s.sequence, sessionFileSuffix)
+	// write to a temporary file first so that readers never see a partial document
+	tmp, err := os.CreateTemp(s.dir, ".tmp-"+sessionFilePrefix)
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	// make sure the content is on disk before the file becomes visible
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(s.dir, name)); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return s.prune(name)
+}
+
+// prune removes the files that are too old or exceed the count or size limits, except the one just written.
+func (s FileSink) prune(keep string) error {
+	if s.maxAge == 0 && s.maxFiles == 0 && s.maxTotalSize == 0 {
+		return nil
+	}
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+	type file struct {
+		name string
+		size int64
+		mod  time.Time
+	}
+	var files []file
+	for _, each := range entries {
+		if each.IsDir() || !strings.HasPrefix(each.Name(), sessionFilePrefix) || !strings.HasSuffix(each.Name(), sessionFileSuffix) {
+			continue
+		}
+		fi, err := each.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, file{name: each.Name(), size: fi.Size(), mod: fi.ModTime()})
+	}
+	// newest first ; names start with the time of failure
+	sort.Slice(files, func(i, j int) bool { return files[i].name > files[j].name })
+	var total int64
+	kept := 0
+	for _, each := range files {
+		expired := s.maxAge > 0 && time.Since(each.mod) > s.maxAge
+		tooMany := s.maxFiles > 0 && kept >= s.maxFiles
+		tooBig := s.maxTotalSize > 0 && total+each.size > s.maxTotalSize
+		if each.name != keep && (expired || tooMany || tooBig) {
+			if err := os.Remove(filepath.Join(s.dir, each.name)); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			continue
+		}
+		total += each.size
+		kept++
+	}
+	return nil
+}
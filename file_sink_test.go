@@ -0,0 +1,95 @@
+package recall
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileSink(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "sessions")
+	r := New(context.Background()).WithCaptureStrategy(RecordingStrategy).WithSink(NewFileSink(dir))
+	r.Call(func(ctx context.Context) error {
+		Slog(ctx).Debug("lookup", "id", 42)
+		return errors.New("not found")
+	})
+	files, _ := filepath.Glob(filepath.Join(dir, "recall-*.json"))
+	if got, want := len(files), 1; got != want {
+		t.Fatalf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	data, _ := os.ReadFile(files[0])
+	var doc struct {
+		Failure struct{ Err string }
+		Records []struct {
+			Level string
+			Msg   string
+			ID    int
+		}
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := doc.Failure.Err, "not found"; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if got, want := doc.Records[0].Level+" "+doc.Records[0].Msg, "DEBUG lookup"; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if got, want := doc.Records[0].ID, 42; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	// no temporary files left
+	if tmps, _ := filepath.Glob(filepath.Join(dir, ".tmp-*")); len(tmps) != 0 {
+		t.Errorf("unexpected temporary files: %v", tmps)
+	}
+}
+
+func TestFileSinkPrune(t *testing.T) {
+	dir := t.TempDir()
+	sink := NewFileSink(dir).WithMaxFiles(2)
+	start := time.Now()
+	for i := range 4 {
+		info := FailureInfo{Time: start.Add(time.Duration(i) * time.Second)}
+		if err := sink.Flush(context.Background(), info, []slog.Record{slog.NewRecord(info.Time, slog.LevelDebug, "m", 0)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "recall-*.json"))
+	if got, want := len(files), 2; got != want {
+		t.Fatalf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if !strings.HasSuffix(files[1], "000004.json") {
+		t.Errorf("expected newest to be kept, got %v", files)
+	}
+
+	// total size allows one file only
+	info, _ := os.Stat(files[1])
+	sink = sink.WithMaxFiles(0).WithMaxTotalSize(info.Size() + 1)
+	sink.Flush(context.Background(), FailureInfo{Time: start.Add(time.Minute)}, nil)
+	files, _ = filepath.Glob(filepath.Join(dir, "recall-*.json"))
+	if got, want := len(files), 1; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+
+	// all others are expired
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(files[0], old, old)
+	sink = NewFileSink(dir).WithMaxAge(time.Minute)
+	sink.Flush(context.Background(), FailureInfo{Time: start.Add(2 * time.Minute)}, nil)
+	files, _ = filepath.Glob(filepath.Join(dir, "recall-*.json"))
+	if got, want := len(files), 1; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
+
+func TestFileSinkZero(t *testing.T) {
+	if err := (FileSink{}).Flush(context.Background(), FailureInfo{}, nil); err == nil {
+		t.Error("expected error")
+	}
+}
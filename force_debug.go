@@ -0,0 +1,67 @@
+package recall
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var forceDebugKey struct{ debug bool }
+
+// ForceDebug returns a new context that makes Recallers and RecallHandlers write Debug log records immediately instead of recording them.
+func ForceDebug(ctx context.Context) context.Context {
+	return context.WithValue(ctx, forceDebugKey, true)
+}
+
+// DebugForced returns true if the context was created by ForceDebug.
+func DebugForced(ctx context.Context) bool {
+	forced, _ := ctx.Value(forceDebugKey).(bool)
+	return forced
+}
+
+// AllowDebugValues returns a check for WithDebugHeader that accepts any of the given header values.
+func AllowDebugValues(values ...string) func(r *http.Request, value string) bool {
+	return func(r *http.Request, value string) bool {
+		return slices.ContainsFunc(values, func(each string) bool {
+			return hmac.Equal([]byte(each), []byte(value))
+		})
+	}
+}
+
+// AllowSignedDebug returns a check for WithDebugHeader that accepts header values created by SignDebug with the same secret
+// that have not expired.
+func AllowSignedDebug(secret []byte) func(r *http.Request, value string) bool {
+	return func(r *http.Request, value string) bool {
+		expires, signature, ok := strings.Cut(value, ".")
+		if !ok {
+			return false
+		}
+		unix, err := strconv.ParseInt(expires, 10, 64)
+		if err != nil || time.Now().Unix() > unix {
+			return false
+		}
+		given, err := hex.DecodeString(signature)
+		if err != nil {
+			return false
+		}
+		return hmac.Equal(given, debugSignature(secret, expires))
+	}
+}
+
+// SignDebug returns a header value, accepted by AllowSignedDebug with the same secret, that is valid until expires.
+func SignDebug(secret []byte, expires time.Time) string {
+	unix := strconv.FormatInt(expires.Unix(), 10)
+	return unix + "." + hex.EncodeToString(debugSignature(secret, unix))
+}
+
+func debugSignature(secret []byte, expires string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(expires))
+	return mac.Sum(nil)
+}
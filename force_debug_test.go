@@ -0,0 +1,84 @@
+package recall
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRecallerForceDebug(t *testing.T) {
+	rec := new(recording)
+	log := slog.New(rec)
+	calls := 0
+	r := New(ForceDebug(ContextWithLogger(context.Background(), log)))
+	r.Call(func(ctx context.Context) error {
+		calls++
+		return willError(ctx)
+	})
+	if got, want := calls, 1; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if got, want := len(rec.records), 1; got != want {
+		t.Fatalf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if got, want := rec.records[0].Message, "[RECALL] will error"; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
+
+func TestRecallHandlerDebugHeader(t *testing.T) {
+	rec := new(recording)
+	old := slog.Default()
+	slog.SetDefault(slog.New(rec))
+	defer slog.SetDefault(old)
+	var forced bool
+	h := NewRecallHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forced = DebugForced(r.Context())
+		Slog(r.Context()).Debug("live")
+		Slog(r.Context()).With("k", "v").Debug("with")
+		Slog(r.Context()).WithGroup("g").Debug("group")
+	})).WithDebugHeader("X-Debug", AllowDebugValues("let-me-in"))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Debug", "wrong")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if got, want := len(rec.records), 0; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+
+	req.Header.Set("X-Debug", "let-me-in")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if got, want := len(rec.records), 3; got != want {
+		t.Fatalf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if got, want := rec.records[0].Message, "[RECALL] live"; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if got, want := rec.records[1].Message, "[RECALL] with"; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if !forced {
+		t.Error("expected forced debug context")
+	}
+}
+
+func TestAllowSignedDebug(t *testing.T) {
+	secret := []byte("secret")
+	check := AllowSignedDebug(secret)
+	req := httptest.NewRequest("GET", "/", nil)
+	if !check(req, SignDebug(secret, time.Now().Add(time.Minute))) {
+		t.Error("expected valid signature")
+	}
+	if check(req, SignDebug(secret, time.Now().Add(-time.Minute))) {
+		t.Error("expected expired signature")
+	}
+	if check(req, SignDebug([]byte("other"), time.Now().Add(time.Minute))) {
+		t.Error("expected invalid signature")
+	}
+	if check(req, "garbage") {
+		t.Error("expected invalid value")
+	}
+}
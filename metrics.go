@@ -0,0 +1,96 @@
+package recall
+
+import (
+	"expvar"
+	"sync"
+)
+
+// Metric identifies a counter of recall activity.
+type Metric string
+
+const (
+	// MetricCalls counts the calls of a Recaller and the requests handled by a RecallHandler.
+	MetricCalls Metric = "calls"
+	// MetricFailures counts the calls that returned an error and the requests that failed.
+	MetricFailures Metric = "failures"
+	// MetricRecalls counts the failures for which debug logs were produced, by flushing records or by a rerun.
+	MetricRecalls Metric = "recalls"
+	// MetricRecordsRecorded counts the log records that were recorded.
+	MetricRecordsRecorded Metric = "records_recorded"
+	// MetricRecordsFlushed counts the recorded log records that were flushed.
+	MetricRecordsFlushed Metric = "records_flushed"
+	// MetricRecordsDropped counts the recorded log records that were discarded.
+	MetricRecordsDropped Metric = "records_dropped"
+	// MetricErrorsFiltered counts the errors that were skipped by the error filter.
+	MetricErrorsFiltered Metric = "errors_filtered"
+	// MetricPanicsRecovered counts the recovered panics.
+	MetricPanicsRecovered Metric = "panics_recovered"
+	// MetricReruns counts the functions that were called a second time by the RecallOnErrorStrategy.
+	MetricReruns Metric = "reruns"
+	// MetricRecallsSuppressed counts the failures for which no recall happened because the RecallBudget was exhausted.
+	MetricRecallsSuppressed Metric = "recalls_suppressed"
+	// MetricRecallsDeduplicated counts the failures for which no recall happened because the Deduplicator has seen the failure before.
+	MetricRecallsDeduplicated Metric = "recalls_deduplicated"
+	// MetricRecordsLate counts the log records that were logged after the call or request has completed.
+	MetricRecordsLate Metric = "records_late"
+	// MetricRecordsFailed counts the flushed log records that a HandlerSink could not write and wrote to its fallback handler instead.
+	MetricRecordsFailed Metric = "records_failed"
+)
+
+// MetricLabels qualify a Metric.
+type MetricLabels struct {
+	// Strategy is "recall_on_error" or "recording".
+	Strategy string
+	// Route is the pattern of the route that handled the request, for a RecallHandler only.
+	Route string
+}
+
+// Metrics receives counts of recall activity. Implementations must be safe for concurrent use.
+type Metrics interface {
+	Add(m Metric, delta int64, labels MetricLabels)
+}
+
+// String returns the name of the strategy as used in MetricLabels.
+func (s captureStrategy) String() string {
+	if s == RecordingStrategy {
+		return "recording"
+	}
+	return "recall_on_error"
+}
+
+// ExpvarMetrics publishes the counts as an expvar.Map with a map per Metric, keyed by strategy and route.
+type ExpvarMetrics struct {
+	vars *expvar.Map
+	mu   *sync.Mutex
+}
+
+// NewExpvarMetrics returns an ExpvarMetrics that is published using the name.
+// Like expvar.Publish, it panics if the name is already in use.
+func NewExpvarMetrics(name string) ExpvarMetrics {
+	return ExpvarMetrics{vars: expvar.NewMap(name), mu: new(sync.Mutex)}
+}
+
+// Add implements Metrics
+func (e ExpvarMetrics) Add(m Metric, delta int64, labels MetricLabels) {
+	key := labels.Strategy
+	if labels.Route != "" {
+		key += " " + labels.Route
+	}
+	v := e.vars.Get(string(m))
+	if v == nil {
+		e.mu.Lock()
+		if v = e.vars.Get(string(m)); v == nil {
+			v = new(expvar.Map)
+			e.vars.Set(string(m), v)
+		}
+		e.mu.Unlock()
+	}
+	v.(*expvar.Map).Add(key, delta)
+}
+
+// count adds to the metric if metrics are set.
+func count(metrics Metrics, m Metric, delta int64, labels MetricLabels) {
+	if metrics != nil && delta != 0 {
+		metrics.Add(m, delta, labels)
+	}
+}
@@ -0,0 +1,99 @@
+package recall
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+type countingMetrics struct {
+	mu     sync.Mutex
+	counts map[string]int64
+}
+
+func (c *countingMetrics) Add(m Metric, delta int64, labels MetricLabels) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.counts == nil {
+		c.counts = map[string]int64{}
+	}
+	c.counts[string(m)+" "+labels.Strategy+" "+labels.Route] += delta
+}
+
+func (c *countingMetrics) get(key string) int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.counts[key]
+}
+
+func TestMetricsRecallOnError(t *testing.T) {
+	m := new(countingMetrics)
+	r := New(context.Background()).WithMetrics(m)
+	r.Call(willError)
+	r.Call(noError)
+	r.Call(willPanic)
+	for key, want := range map[string]int64{
+		"calls recall_on_error ":            3,
+		"failures recall_on_error ":         2,
+		"recalls recall_on_error ":          2,
+		"reruns recall_on_error ":           2,
+		"panics_recovered recall_on_error ": 2,
+	} {
+		if got := m.get(key); got != want {
+			t.Errorf("%s: got [%v] want [%v]", key, got, want)
+		}
+	}
+}
+
+func TestMetricsRecording(t *testing.T) {
+	m := new(countingMetrics)
+	filtered := errors.New("filtered")
+	r := New(context.Background()).WithCaptureStrategy(RecordingStrategy).WithMetrics(m).WithErrorFilter(func(err error) bool {
+		return err != filtered
+	})
+	r.Call(willError)
+	r.Call(noError)
+	r.Call(func(ctx context.Context) error {
+		Slog(ctx).Debug("skip me")
+		return filtered
+	})
+	for key, want := range map[string]int64{
+		"calls recording ":            3,
+		"failures recording ":         2,
+		"recalls recording ":          1,
+		"errors_filtered recording ":  1,
+		"records_recorded recording ": 3,
+		"records_flushed recording ":  1,
+		"records_dropped recording ":  2,
+	} {
+		if got := m.get(key); got != want {
+			t.Errorf("%s: got [%v] want [%v]", key, got, want)
+		}
+	}
+}
+
+func TestMetricsRecallHandlerRoute(t *testing.T) {
+	m := new(countingMetrics)
+	mux := http.NewServeMux()
+	mux.Handle("GET /fail/{id}", erroringHandler{})
+	h := NewRecallHandler(mux).WithMetrics(m)
+	req, _ := http.NewRequest("GET", "/fail/1", nil)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if got, want := m.get("failures recording GET /fail/{id}"), int64(1); got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
+
+func TestExpvarMetrics(t *testing.T) {
+	e := NewExpvarMetrics("recall_test")
+	e.Add(MetricCalls, 2, MetricLabels{Strategy: "recording", Route: "/x"})
+	e.Add(MetricCalls, 1, MetricLabels{Strategy: "recording", Route: "/x"})
+	calls := expvar.Get("recall_test").(*expvar.Map).Get("calls").(*expvar.Map)
+	if got, want := calls.Get("recording /x").String(), "3"; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
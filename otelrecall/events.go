@@ -0,0 +1,83 @@
+package otelrecall
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// AddSpanEvents adds each record as an event to the span in the context.
+// The name of an event is the message of the record ; its attributes are converted from the record attributes.
+// Use it as flush hook, optionally disabling the log replay, e.g.
+//
+//	recall.NewRecallHandler(mux).WithFlushHook(otelrecall.AddSpanEvents).WithLogReplay(false)
+func AddSpanEvents(ctx context.Context, records []slog.Record) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+	for _, each := range records {
+		attrs := []attribute.KeyValue{attribute.String("level", each.Level.String())}
+		each.Attrs(func(a slog.Attr) bool {
+			attrs = appendAttr(attrs, "", a)
+			return true
+		})
+		span.AddEvent(each.Message, trace.WithTimestamp(each.Time), trace.WithAttributes(attrs...))
+	}
+}
+
+// appendAttr converts a slog attribute into one or more OpenTelemetry attributes.
+// Groups are flattened using dotted keys.
+func appendAttr(list []attribute.KeyValue, prefix string, a slog.Attr) []attribute.KeyValue {
+	v := a.Value.Resolve()
+	key := prefix + a.Key
+	switch v.Kind() {
+	case slog.KindGroup:
+		if a.Key != "" {
+			prefix = key + "."
+		}
+		for _, each := range v.Group() {
+			list = appendAttr(list, prefix, each)
+		}
+		return list
+	case slog.KindString:
+		return append(list, attribute.String(key, v.String()))
+	case slog.KindInt64:
+		return append(list, attribute.Int64(key, v.Int64()))
+	case slog.KindUint64:
+		if u := v.Uint64(); u <= math.MaxInt64 {
+			return append(list, attribute.Int64(key, int64(u)))
+		}
+		return append(list, attribute.String(key, v.String()))
+	case slog.KindFloat64:
+		return append(list, attribute.Float64(key, v.Float64()))
+	case slog.KindBool:
+		return append(list, attribute.Bool(key, v.Bool()))
+	case slog.KindDuration:
+		return append(list, attribute.String(key, v.Duration().String()))
+	case slog.KindTime:
+		return append(list, attribute.String(key, v.Time().Format(time.RFC3339Nano)))
+	}
+	switch x := v.Any().(type) {
+	case []string:
+		return append(list, attribute.StringSlice(key, x))
+	case []int:
+		return append(list, attribute.IntSlice(key, x))
+	case []int64:
+		return append(list, attribute.Int64Slice(key, x))
+	case []float64:
+		return append(list, attribute.Float64Slice(key, x))
+	case []bool:
+		return append(list, attribute.BoolSlice(key, x))
+	case error:
+		return append(list, attribute.String(key, x.Error()))
+	case fmt.Stringer:
+		return append(list, attribute.String(key, x.String()))
+	}
+	return append(list, attribute.String(key, fmt.Sprint(v.Any())))
+}
@@ -0,0 +1,92 @@
+package otelrecall
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/emicklei/recall"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestAppendAttr(t *testing.T) {
+	attrs := []slog.Attr{
+		slog.String("s", "v"),
+		slog.Int("i", 42),
+		slog.Uint64("u", 7),
+		slog.Float64("f", 1.5),
+		slog.Bool("b", true),
+		slog.Duration("d", time.Second),
+		slog.Group("g", slog.Int("n", 1), slog.Group("h", slog.String("m", "x"))),
+		slog.Any("ss", []string{"a", "b"}),
+		slog.Any("err", errors.New("bad")),
+		slog.Any("other", struct{ A int }{1}),
+	}
+	var list []attribute.KeyValue
+	for _, each := range attrs {
+		list = appendAttr(list, "", each)
+	}
+	got := map[attribute.Key]attribute.Value{}
+	for _, each := range list {
+		got[each.Key] = each.Value
+	}
+	for key, want := range map[attribute.Key]attribute.Value{
+		"s":     attribute.StringValue("v"),
+		"i":     attribute.Int64Value(42),
+		"u":     attribute.Int64Value(7),
+		"f":     attribute.Float64Value(1.5),
+		"b":     attribute.BoolValue(true),
+		"d":     attribute.StringValue("1s"),
+		"g.n":   attribute.Int64Value(1),
+		"g.h.m": attribute.StringValue("x"),
+		"ss":    attribute.StringSliceValue([]string{"a", "b"}),
+		"err":   attribute.StringValue("bad"),
+		"other": attribute.StringValue("{1}"),
+	} {
+		if got[key] != want {
+			t.Errorf("%s: got [%v] want [%v]", key, got[key].Emit(), want.Emit())
+		}
+	}
+}
+
+func TestRecallHandlerSpanEventsWithoutLogReplay(t *testing.T) {
+	col := new(collector)
+	old := slog.Default()
+	slog.SetDefault(slog.New(col))
+	defer slog.SetDefault(old)
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	failing := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recall.Slog(r.Context()).Debug("lookup", "id", 42)
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	h := recall.NewRecallHandler(failing).WithFlushHook(AddSpanEvents).WithLogReplay(false)
+
+	ctx, span := provider.Tracer("test").Start(context.Background(), "request")
+	req := httptest.NewRequest("GET", "/", nil).WithContext(ctx)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	span.End()
+
+	// only the failure itself is logged
+	if got, want := len(col.records), 1; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	events := exporter.GetSpans()[0].Events
+	if got, want := len(events), 1; got != want {
+		t.Fatalf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if got, want := events[0].Name, "lookup"; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if got, want := events[0].Attributes[1], attribute.Int64("id", 42); got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
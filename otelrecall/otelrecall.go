@@ -0,0 +1,55 @@
+// Package otelrecall correlates recalled log records with OpenTelemetry traces.
+package otelrecall
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/emicklei/recall"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// TraceIDKey is the attribute key for the trace ID of a recorded log record.
+	TraceIDKey = "trace_id"
+	// SpanIDKey is the attribute key for the span ID of a recorded log record.
+	SpanIDKey = "span_id"
+)
+
+// Instrument returns the Recaller that stamps recorded log records with trace information
+// and marks the failing span when recorded records are written.
+func Instrument(r recall.Recaller) recall.Recaller {
+	return r.WithRecordHook(Stamp).WithFlushHook(MarkSpan)
+}
+
+// InstrumentHandler returns the RecallHandler that stamps recorded log records with trace information
+// and marks the failing span when recorded records are written.
+func InstrumentHandler(h recall.RecallHandler) recall.RecallHandler {
+	return h.WithRecordHook(Stamp).WithFlushHook(MarkSpan)
+}
+
+// Stamp adds the trace and span ID of the span in the context to the record.
+// The record is returned unchanged if the context has no valid span.
+func Stamp(ctx context.Context, record slog.Record) slog.Record {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return record
+	}
+	stamped := record.Clone()
+	stamped.AddAttrs(
+		slog.String(TraceIDKey, sc.TraceID().String()),
+		slog.String(SpanIDKey, sc.SpanID().String()))
+	return stamped
+}
+
+// MarkSpan sets the attributes "recall" and "recall.records" on the span in the context.
+func MarkSpan(ctx context.Context, records []slog.Record) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+	span.SetAttributes(
+		attribute.Bool("recall", true),
+		attribute.Int("recall.records", len(records)))
+}
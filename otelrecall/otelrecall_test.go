@@ -0,0 +1,85 @@
+package otelrecall
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/emicklei/recall"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+type collector struct {
+	records []slog.Record
+}
+
+func (c *collector) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= slog.LevelInfo
+}
+func (c *collector) Handle(ctx context.Context, record slog.Record) error {
+	c.records = append(c.records, record)
+	return nil
+}
+func (c *collector) WithAttrs(attrs []slog.Attr) slog.Handler { return c }
+func (c *collector) WithGroup(group string) slog.Handler      { return c }
+
+func TestInstrument(t *testing.T) {
+	col := new(collector)
+	old := slog.Default()
+	slog.SetDefault(slog.New(col))
+	defer slog.SetDefault(old)
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	ctx, span := provider.Tracer("test").Start(context.Background(), "failing")
+
+	r := Instrument(recall.New(ctx).WithCaptureStrategy(recall.RecordingStrategy))
+	r.Call(func(ctx context.Context) error {
+		recall.Slog(ctx).Debug("without context")
+		recall.Slog(ctx).DebugContext(ctx, "with context")
+		return errors.New("failed")
+	})
+	span.End()
+
+	if got, want := len(col.records), 2; got != want {
+		t.Fatalf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	for _, each := range col.records {
+		attrs := map[string]string{}
+		each.Attrs(func(a slog.Attr) bool {
+			attrs[a.Key] = a.Value.String()
+			return true
+		})
+		if got, want := attrs[TraceIDKey], span.SpanContext().TraceID().String(); got != want {
+			t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+		}
+		if got, want := attrs[SpanIDKey], span.SpanContext().SpanID().String(); got != want {
+			t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+		}
+	}
+	spans := exporter.GetSpans()
+	if got, want := len(spans), 1; got != want {
+		t.Fatalf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	marks := map[attribute.Key]attribute.Value{}
+	for _, each := range spans[0].Attributes {
+		marks[each.Key] = each.Value
+	}
+	if !marks["recall"].AsBool() {
+		t.Error("expected recall attribute")
+	}
+	if got, want := marks["recall.records"].AsInt64(), int64(2); got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
+
+func TestStampWithoutSpan(t *testing.T) {
+	record := slog.NewRecord(time.Time{}, slog.LevelDebug, "test", 0)
+	if got, want := Stamp(context.Background(), record).NumAttrs(), 0; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
@@ -0,0 +1,112 @@
+package recall
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+// multipartSummary describes a part of a multipart payload without its content.
+type multipartSummary struct {
+	Name        string `json:"name"`
+	Filename    string `json:"filename,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Size        int64  `json:"size"`
+}
+
+func (s multipartSummary) String() string {
+	return fmt.Sprintf("%s:%s(%s,%d)", s.Name, s.Filename, s.ContentType, s.Size)
+}
+
+// maxDecodedPayload is the maximum number of bytes of a decompressed payload that are kept.
+const maxDecodedPayload = 64 << 10
+
+// decodeContent returns the decompressed data if the content encoding is gzip.
+// The data is returned unchanged if it is not encoded or cannot be decoded.
+// Truncated data is decoded as far as possible, up to the limit or maxDecodedPayload ;
+// truncated is true if the decompressed data exceeds that.
+func decodeContent(contentEncoding string, data []byte, limit int) (decoded []byte, truncated bool) {
+	if !strings.EqualFold(strings.TrimSpace(contentEncoding), "gzip") || len(data) == 0 {
+		return data, false
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return data, false
+	}
+	max := min(limit, maxDecodedPayload)
+	decoded, err = io.ReadAll(io.LimitReader(zr, int64(max)+1))
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return data, false
+	}
+	if len(decoded) > max {
+		return decoded[:max], true
+	}
+	return decoded, false
+}
+
+// payloadValue returns a log value for the recorded payload depending on its content type.
+// JSON is logged as structured value, form values as a map, multipart as part summaries,
+// text as is and any other binary content as base64.
+func payloadValue(contentType string, data []byte, truncated bool, limit, total int) slog.Value {
+	mediaType, params, _ := mime.ParseMediaType(contentType)
+	switch {
+	case !truncated && (mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")):
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		var doc any
+		if err := dec.Decode(&doc); err == nil && !dec.More() {
+			return slog.AnyValue(doc)
+		}
+	case !truncated && mediaType == "application/x-www-form-urlencoded":
+		if values, err := url.ParseQuery(string(data)); err == nil {
+			return slog.AnyValue(values)
+		}
+	case strings.HasPrefix(mediaType, "multipart/") && params["boundary"] != "":
+		return slog.AnyValue(multipartSummaries(data, params["boundary"]))
+	}
+	if isText(mediaType, data) {
+		return slog.StringValue(recordedPayload(data, truncated, limit, total))
+	}
+	return slog.StringValue(recordedPayload([]byte("base64:"+base64.StdEncoding.EncodeToString(data)), truncated, limit, total))
+}
+
+// multipartSummaries returns the summaries of the parts that could be read.
+func multipartSummaries(data []byte, boundary string) (list []multipartSummary) {
+	mr := multipart.NewReader(bytes.NewReader(data), boundary)
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			return
+		}
+		size, _ := io.Copy(io.Discard, part)
+		list = append(list, multipartSummary{
+			Name:        part.FormName(),
+			Filename:    part.FileName(),
+			ContentType: part.Header.Get("Content-Type"),
+			Size:        size,
+		})
+	}
+}
+
+// isText returns true if the payload can be logged as a string.
+func isText(mediaType string, data []byte) bool {
+	switch {
+	case strings.HasPrefix(mediaType, "text/"),
+		strings.HasSuffix(mediaType, "json"), strings.HasSuffix(mediaType, "xml"),
+		mediaType == "application/x-www-form-urlencoded":
+		return true
+	case mediaType == "", mediaType == "application/octet-stream":
+		// unknown, check content
+		return utf8.Valid(data) && !bytes.ContainsRune(data, 0)
+	}
+	return false
+}
@@ -0,0 +1,116 @@
+package recall
+
+import (
+	"bytes"
+	"compress/gzip"
+	"math"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestPayloadValueJSON(t *testing.T) {
+	v := payloadValue("application/json", []byte(`{"a":1,"b":[true]}`), false, 100, 18)
+	doc, ok := v.Any().(map[string]any)
+	if !ok {
+		t.Fatalf("expected map, got %T", v.Any())
+	}
+	if got, want := doc["a"].(interface{ String() string }).String(), "1"; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	// truncated JSON is logged as text
+	v = payloadValue("application/json", []byte(`{"a":`), true, 5, 18)
+	if got, want := v.String(), `{"a":..(5 of 18)`; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
+
+func TestPayloadValueForm(t *testing.T) {
+	v := payloadValue("application/x-www-form-urlencoded", []byte("a=1&a=2&b=3"), false, 100, 11)
+	values, ok := v.Any().(url.Values)
+	if !ok {
+		t.Fatalf("expected url.Values, got %T", v.Any())
+	}
+	if got, want := len(values["a"]), 2; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
+
+func TestPayloadValueMultipart(t *testing.T) {
+	buf := new(bytes.Buffer)
+	mw := multipart.NewWriter(buf)
+	mw.WriteField("name", "joe")
+	fw, _ := mw.CreateFormFile("upload", "photo.jpg")
+	fw.Write(make([]byte, 1000))
+	mw.Close()
+	v := payloadValue(mw.FormDataContentType(), buf.Bytes(), false, buf.Len(), buf.Len())
+	parts, ok := v.Any().([]multipartSummary)
+	if !ok {
+		t.Fatalf("expected summaries, got %T", v.Any())
+	}
+	if got, want := len(parts), 2; got != want {
+		t.Fatalf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if got, want := parts[1], (multipartSummary{Name: "upload", Filename: "photo.jpg", ContentType: "application/octet-stream", Size: 1000}); got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
+
+func TestPayloadValueBinary(t *testing.T) {
+	v := payloadValue("application/x-protobuf", []byte{0x08, 0x96, 0x01}, true, 3, 10)
+	if got, want := v.String(), "base64:CJYB..(3 of 10)"; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	v = payloadValue("", []byte("plain"), false, 10, 5)
+	if got, want := v.String(), "plain"; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
+
+func TestDecodeContentGzip(t *testing.T) {
+	buf := new(bytes.Buffer)
+	zw := gzip.NewWriter(buf)
+	zw.Write([]byte("hello compressed world"))
+	zw.Close()
+	for _, each := range []struct {
+		encoding  string
+		data      []byte
+		limit     int
+		want      string
+		truncated bool
+	}{
+		{"gzip", buf.Bytes(), 100, "hello compressed world", false},
+		{"gzip", buf.Bytes(), 5, "hello", true},
+		{"", []byte("raw"), 100, "raw", false},
+	} {
+		got, truncated := decodeContent(each.encoding, each.data, each.limit)
+		if string(got) != each.want {
+			t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", string(got), each.want)
+		}
+		if truncated != each.truncated {
+			t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", truncated, each.truncated)
+		}
+	}
+}
+
+func TestDecodeContentGzipBounded(t *testing.T) {
+	buf := new(bytes.Buffer)
+	zw := gzip.NewWriter(buf)
+	zw.Write(bytes.Repeat([]byte("a"), 10<<20))
+	zw.Close()
+	got, truncated := decodeContent("gzip", buf.Bytes(), math.MaxInt)
+	if got, want := len(got), maxDecodedPayload; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if !truncated {
+		t.Error("expected truncated")
+	}
+	h := NewRecallHandler(nil)
+	header := http.Header{"Content-Encoding": {"gzip"}}
+	v := h.payload(header, buf.Bytes(), math.MaxInt, buf.Len())
+	if got, want := v.String(), "..(65536 of more)"; !strings.HasSuffix(got, want) {
+		t.Errorf("got [%v] want suffix [%v]", got[max(0, len(got)-20):], want)
+	}
+}
@@ -0,0 +1,64 @@
+// Package promrecall provides a Prometheus collector for the metrics of recall activity.
+package promrecall
+
+import (
+	"github.com/emicklei/recall"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var metrics = []recall.Metric{
+	recall.MetricCalls,
+	recall.MetricFailures,
+	recall.MetricRecalls,
+	recall.MetricRecordsRecorded,
+	recall.MetricRecordsFlushed,
+	recall.MetricRecordsDropped,
+	recall.MetricErrorsFiltered,
+	recall.MetricPanicsRecovered,
+	recall.MetricReruns,
+	recall.MetricRecallsSuppressed,
+	recall.MetricRecallsDeduplicated,
+	recall.MetricRecordsLate,
+	recall.MetricRecordsFailed,
+}
+
+// Collector implements recall.Metrics and prometheus.Collector.
+// Each recall.Metric is a counter named "<namespace>_<metric>_total" with the labels "strategy" and "route".
+type Collector struct {
+	counters map[recall.Metric]*prometheus.CounterVec
+}
+
+// NewCollector returns a Collector using the namespace for the counter names, e.g. "recall".
+// Register it with a prometheus.Registerer and pass it to WithMetrics.
+func NewCollector(namespace string) *Collector {
+	c := &Collector{counters: map[recall.Metric]*prometheus.CounterVec{}}
+	for _, each := range metrics {
+		c.counters[each] = prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      string(each) + "_total",
+			Help:      "Number of " + string(each) + " of recall activity.",
+		}, []string{"strategy", "route"})
+	}
+	return c
+}
+
+// Add implements recall.Metrics
+func (c *Collector) Add(m recall.Metric, delta int64, labels recall.MetricLabels) {
+	if counter, ok := c.counters[m]; ok {
+		counter.WithLabelValues(labels.Strategy, labels.Route).Add(float64(delta))
+	}
+}
+
+// Describe implements prometheus.Collector
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, each := range metrics {
+		c.counters[each].Describe(ch)
+	}
+}
+
+// Collect implements prometheus.Collector
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	for _, each := range metrics {
+		c.counters[each].Collect(ch)
+	}
+}
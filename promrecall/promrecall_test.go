@@ -0,0 +1,36 @@
+package promrecall
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/emicklei/recall"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCollector(t *testing.T) {
+	c := NewCollector("recall")
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(c)
+
+	r := recall.New(context.Background()).WithCaptureStrategy(recall.RecordingStrategy).WithMetrics(c)
+	r.Call(func(ctx context.Context) error {
+		recall.Slog(ctx).Debug("failing")
+		return errors.New("failed")
+	})
+
+	expected := `
+# HELP recall_failures_total Number of failures of recall activity.
+# TYPE recall_failures_total counter
+recall_failures_total{route="",strategy="recording"} 1
+# HELP recall_records_flushed_total Number of records_flushed of recall activity.
+# TYPE recall_records_flushed_total counter
+recall_records_flushed_total{route="",strategy="recording"} 1
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(expected), "recall_failures_total", "recall_records_flushed_total"); err != nil {
+		t.Error(err)
+	}
+}
@@ -2,6 +2,7 @@ package recall
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"log/slog"
@@ -9,6 +10,7 @@ import (
 	"net/http"
 	"runtime/debug"
 	"strings"
+	"time"
 )
 
 type RecallHandler struct {
@@ -16,12 +18,33 @@ type RecallHandler struct {
 	messageFormat    string
 	handlePanic      bool
 	bufferCapacity   int
+	responseCapacity int
+	prefetchCapacity int
 	headerFilter     func(in http.Header) (out http.Header)
-	statusCodeFilter func(statusCode int) bool
+	failurePolicy    FailurePolicy
+	panicResponse    func(w http.ResponseWriter, r *http.Request, recovered any)
+	enabled          bool
+	routes           []routeConfig
+	redactor         *Redactor
+	requestMetadata  bool
+	requestAttrs     func(r *http.Request) []slog.Attr
+	recordHook       func(ctx context.Context, record slog.Record) slog.Record
+	flushHooks       []func(ctx context.Context, records []slog.Record)
+	logReplay        bool
+	sink             Sink
+	metrics          Metrics
+	budget           *RecallBudget
+	deduplicator     *Deduplicator
+	captureMode      captureMode
+	debugHeader      string
+	debugCheck       func(r *http.Request, value string) bool
+	config           *Config
+	captureLevel     slog.Level
+	taskWait         time.Duration
 }
 
 // NewRecallHandler uses the RecordingStrategy for capturing logs during HTTP request processing.
-// It will write the Debug logs if the request fails (http status >= 400) and details about the HTTP request including the payload.
+// It will write the Debug logs if the request fails (by default http status >= 500) and details about the HTTP request including the payload.
 func NewRecallHandler(next http.Handler) RecallHandler {
 	return RecallHandler{
 		next:           next,
@@ -29,6 +52,12 @@ func NewRecallHandler(next http.Handler) RecallHandler {
 		handlePanic:    true,
 		bufferCapacity: math.MaxInt,
 		headerFilter:   nil,
+		panicResponse:  statusOnlyPanicResponse,
+		failurePolicy:  ServerErrorsOnly,
+		enabled:        true,
+		logReplay:      true,
+		captureLevel:   noCaptureLevel,
+		taskWait:       defaultTaskWait,
 	}
 }
 
@@ -39,6 +68,14 @@ func (h RecallHandler) WithPanicRecovery(enabled bool) RecallHandler {
 	return h
 }
 
+// WithPanicResponse sets the function that writes the response after recovering from a panic.
+// It is only called if the handler did not already send the response headers.
+// Default is writing the status 500 (Internal Server Error) without a body ; see also ProblemJSONPanicResponse.
+func (h RecallHandler) WithPanicResponse(f func(w http.ResponseWriter, r *http.Request, recovered any)) RecallHandler {
+	h.panicResponse = f
+	return h
+}
+
 // WithMessageFormat sets the message format for the debug log message.
 // Must contains a single %s placeholder for the original message.
 func (h RecallHandler) WithMessageFormat(format string) RecallHandler {
@@ -55,6 +92,22 @@ func (h RecallHandler) WithRequestBodyCapture(maxBytes int) RecallHandler {
 	return h
 }
 
+// WithEagerRequestBodyCapture reads and records up to maxBytes of the request body before calling the next handler.
+// The handler reads these bytes as if the body was not read before.
+// Use it to log the start of the request body even if the handler fails before (completely) reading it.
+// At most the limit set by WithRequestBodyCapture is read ; nothing is read if that limit is zero.
+func (h RecallHandler) WithEagerRequestBodyCapture(maxBytes int) RecallHandler {
+	h.prefetchCapacity = maxBytes
+	return h
+}
+
+// WithResponseBodyCapture enables recording the response body, up to a limit, for logging on failure.
+// The response headers are logged too, using the header filter if set. Default is no response capture.
+func (h RecallHandler) WithResponseBodyCapture(maxBytes int) RecallHandler {
+	h.responseCapacity = maxBytes
+	return h
+}
+
 // WithHeaderFilter allows you to modify the request headers before producing a log entry.
 // This can be used to mask or remove sensitive information such as tokens or cookies.
 func (h RecallHandler) WithHeaderFilter(f func(in http.Header) (out http.Header)) RecallHandler {
@@ -62,24 +115,190 @@ func (h RecallHandler) WithHeaderFilter(f func(in http.Header) (out http.Header)
 	return h
 }
 
+// WithDebugHeader makes the handler write Debug log records immediately, instead of recording them,
+// for requests with the header if the check accepts its value. See AllowDebugValues and AllowSignedDebug.
+// The request context is marked with ForceDebug. Requests with a context marked with ForceDebug are always handled this way.
+func (h RecallHandler) WithDebugHeader(name string, check func(r *http.Request, value string) bool) RecallHandler {
+	h.debugHeader = name
+	h.debugCheck = check
+	return h
+}
+
+// WithRedactor sets the Redactor to mask sensitive information in the headers and payloads of the request and response,
+// and in the attributes of the recorded log records. See NewRedactor for the defaults.
+func (h RecallHandler) WithRedactor(r Redactor) RecallHandler {
+	h.redactor = &r
+	return h
+}
+
+// WithRequestMetadata enables logging more details of a failed request: the client address
+// (using the Forwarded or X-Forwarded-For header if present), the route pattern and path values,
+// the protocol, TLS information, the duration and the number of bytes read and written.
+func (h RecallHandler) WithRequestMetadata(enabled bool) RecallHandler {
+	h.requestMetadata = enabled
+	return h
+}
+
+// WithRequestAttrs sets the function that returns extra attributes to log for a failed request,
+// e.g. the identity of the user.
+func (h RecallHandler) WithRequestAttrs(f func(r *http.Request) []slog.Attr) RecallHandler {
+	h.requestAttrs = f
+	return h
+}
+
+// WithRecordHook sets the function that can change a log record before it is recorded, e.g. to add trace information.
+// The context is the one passed to the logger or the request context if the record was logged without one.
+func (h RecallHandler) WithRecordHook(hook func(ctx context.Context, record slog.Record) slog.Record) RecallHandler {
+	h.recordHook = hook
+	return h
+}
+
+// WithFlushHook adds a function that is called with the recorded log records after these are written on failure.
+func (h RecallHandler) WithFlushHook(hook func(ctx context.Context, records []slog.Record)) RecallHandler {
+	h.flushHooks = append(h.flushHooks[:len(h.flushHooks):len(h.flushHooks)], hook)
+	return h
+}
+
+// WithLogReplay enables or disables writing the recorded log records to the sink on failure. Default is true.
+// Disable it if the records are only needed by a flush hook, e.g. to add them to a trace.
+// The failure of the request itself is still logged.
+func (h RecallHandler) WithLogReplay(enabled bool) RecallHandler {
+	h.logReplay = enabled
+	return h
+}
+
+// WithSink sets the Sink to write the recorded log records to on failure.
+// Default is a HandlerSink using the handler of the default logger.
+// The default sink is created for each flush ; to set its fallback handler or error function, use e.g.
+// WithSink(NewHandlerSink(nil, "[RECALL] %s").WithFallback(h)).
+func (h RecallHandler) WithSink(s Sink) RecallHandler {
+	h.sink = s
+	return h
+}
+
+// WithCaptureMode sets how log records are captured by the RecordingStrategy. Default is RecordsCaptureMode.
+// Use SnapshotCaptureMode to write the values as they were when logged ; use EncodedCaptureMode to reduce memory.
+func (h RecallHandler) WithCaptureMode(mode captureMode) RecallHandler {
+	h.captureMode = mode
+	return h
+}
+
+// WithTaskWait sets the maximum time to wait for goroutines, started with Go or GroupGo, to finish before the recorded log records are written.
+// Default is one second. Only used by the RecordingStrategy.
+func (h RecallHandler) WithTaskWait(d time.Duration) RecallHandler {
+	h.taskWait = d
+	return h
+}
+
+// WithMetrics sets the receiver of counts of recall activity, such as an ExpvarMetrics.
+// Counts are labelled with the route pattern if the next handler is a http.ServeMux.
+func (h RecallHandler) WithMetrics(m Metrics) RecallHandler {
+	h.metrics = m
+	return h
+}
+
+// WithBudget sets the RecallBudget that limits the number of recalls ; share it among Recallers and RecallHandlers.
+// If the budget is exhausted then the recorded log records of a failed request are dropped ; the failure itself is still logged.
+func (h RecallHandler) WithBudget(b *RecallBudget) RecallHandler {
+	h.budget = b
+	return h
+}
+
+// WithDeduplicator sets the Deduplicator that limits the recalls of identical recurring failures ; share it among Recallers and RecallHandlers.
+// The recorded log records of a skipped failure are dropped ; the failure itself is still logged.
+func (h RecallHandler) WithDeduplicator(d *Deduplicator) RecallHandler {
+	h.deduplicator = d
+	return h
+}
+
+// WithConfig sets the Config with settings that can be changed at runtime ; these override the settings of the handler if set.
+func (h RecallHandler) WithConfig(c *Config) RecallHandler {
+	h.config = c
+	return h
+}
+
 // WithStatusCodeFilter allows you to decide for which HTTP status code you want to produce log entries.
 // If the function returns true then the status will cause Debug logs ; false will skip it.
+// This replaces the FailurePolicy.
 func (h RecallHandler) WithStatusCodeFilter(f func(statusCode int) bool) RecallHandler {
-	h.statusCodeFilter = f
+	h.failurePolicy = func(o RequestOutcome) bool {
+		return f(o.StatusCode)
+	}
+	return h
+}
+
+// WithFailurePolicy sets the function that decides whether the handling of a request failed.
+// Default is ServerErrorsOnly ; see also AllErrors and ErrorsExcept.
+func (h RecallHandler) WithFailurePolicy(p FailurePolicy) RecallHandler {
+	h.failurePolicy = p
 	return h
 }
 
 // ServeHTTP implements http.Handler
 func (h RecallHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// the route is only needed for routes, metrics and config
+	var route string
+	if len(h.routes) > 0 || h.metrics != nil || h.config != nil {
+		route = h.routePattern(r)
+	}
+	if len(h.routes) > 0 {
+		h = h.forRoute(r, route)
+	}
+	if h.config != nil {
+		if !h.config.Enabled() {
+			h.serveWithoutRecall(w, r)
+			return
+		}
+		h = h.config.applyToHandler(h)
+	}
+	if !h.enabled {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
 	// record request payload up to buffer capacity
 	bodyReader := &limitedBodyRecorder{body: r.Body, limit: h.bufferCapacity, buffer: new(bytes.Buffer)}
+	if prefetch := min(h.prefetchCapacity, h.bufferCapacity); prefetch > 0 && r.Body != nil && r.Body != http.NoBody {
+		bodyReader.prefetch(prefetch)
+	}
 	r.Body = bodyReader
 
-	// create context with recording logger
+	// create context with recording logger unless debug logging is already enabled
 	def := slog.Default()
-	rec := newRecorder(def.Handler(), h.messageFormat)
-	log := slog.New(rec)
-	ctx := ContextWithLogger(r.Context(), log)
+	labels := MetricLabels{Strategy: RecordingStrategy.String(), Route: route}
+	count(h.metrics, MetricCalls, 1, labels)
+	var rec *recorder
+	log := def
+	base := r.Context()
+	if h.debugForced(r) {
+		log = slog.New(debugHandler{def.Handler(), h.messageFormat, h.redactor})
+		base = ForceDebug(base)
+	} else if !def.Handler().Enabled(r.Context(), slog.LevelDebug) {
+		rec = newRecorder(def.Handler(), h.messageFormat)
+		defer rec.release()
+		rec.redactor = h.redactor
+		rec.ctx = r.Context()
+		rec.recordHook = h.recordHook
+		rec.flushHooks = h.flushHooks
+		rec.logReplay = h.logReplay
+		rec.sink = h.sink
+		rec.mode = h.captureMode
+		rec.minLevel = h.captureLevel
+		rec.taskWait = h.taskWait
+		if h.deduplicator != nil || h.budget != nil || h.metrics != nil {
+			rec.allowRecall = func(info FailureInfo) bool {
+				info.Request = r
+				return h.allowRecall(info, labels)
+			}
+		}
+		rec.metrics = h.metrics
+		rec.labels = labels
+		log = slog.New(rec)
+	}
+	ctx := ContextWithLogger(base, log)
+
+	responseWriter := &statusCodeRecorder{ResponseWriter: w, limit: h.responseCapacity, buffer: new(bytes.Buffer)}
+	x := exchange{request: r.WithContext(ctx), body: bodyReader, response: responseWriter, start: time.Now()}
 
 	// do not panic
 	if h.handlePanic {
@@ -87,59 +306,205 @@ func (h RecallHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			// recover from first panic
 			err := recover()
 			if err != nil {
-				rec.flush(ctx)
-				def.Error(fmt.Sprintf(h.messageFormat, "recovered from panic"),
-					"method", r.Method, "url", r.URL, "headers", h.filteredHeaders(r.Header),
-					"payload", bodyReader.recorded(), "status", http.StatusInternalServerError,
-					"err", err, "stack", string(debug.Stack()))
-				w.WriteHeader(http.StatusInternalServerError)
+				if err == http.ErrAbortHandler {
+					// the server knows how to abort the response
+					panic(err)
+				}
+				count(h.metrics, MetricPanicsRecovered, 1, labels)
+				count(h.metrics, MetricFailures, 1, labels)
+				// cannot change the response if headers were sent
+				if !responseWriter.wroteHeader {
+					h.panicResponse(responseWriter, x.request, err)
+				}
+				attrs := append(h.failureAttrs(x), slog.Any("err", err), slog.String("stack", string(debug.Stack())))
+				h.recall(ctx, rec, FailureInfo{Recovered: err, Request: x.request, StatusCode: responseWriter.statusCode, Attrs: attrs})
+				def.LogAttrs(r.Context(), slog.LevelError, fmt.Sprintf(h.messageFormat, "recovered from panic"), attrs...)
 				return
 			}
 		}()
 	}
 
 	// serve the request
-	responseWriter := &statusCodeRecorder{ResponseWriter: w}
-	h.next.ServeHTTP(responseWriter, r.WithContext(ctx))
+	h.next.ServeHTTP(responseWriter.wrapped(), x.request)
+	// the server sends 200 if nothing was written
+	responseWriter.implicitOK()
 
 	// did it fail?
-	fail := responseWriter.statusCode >= http.StatusInternalServerError
-	if h.statusCodeFilter != nil {
-		// override fail logic with custom filter
-		fail = h.statusCodeFilter(responseWriter.statusCode)
+	fail := h.failurePolicy(RequestOutcome{
+		Request:    x.request,
+		StatusCode: responseWriter.statusCode,
+		Latency:    time.Since(x.start),
+		Header:     responseWriter.Header(),
+	})
+	if !fail {
+		if h.config != nil && h.config.flushAlways(x.request, labels.Route) {
+			h.recall(ctx, rec, FailureInfo{Request: x.request, StatusCode: responseWriter.statusCode, Attrs: h.failureAttrs(x)})
+		}
+		return
+	}
+	count(h.metrics, MetricFailures, 1, labels)
+	attrs := h.failureAttrs(x)
+	h.recall(ctx, rec, FailureInfo{Request: x.request, StatusCode: responseWriter.statusCode, Attrs: attrs})
+	slog.LogAttrs(r.Context(), slog.LevelInfo, fmt.Sprintf(h.messageFormat, "HTTP request handling failed"), attrs...)
+}
+
+// serveWithoutRecall serves the request without recording ; a panic is still recovered if enabled.
+func (h RecallHandler) serveWithoutRecall(w http.ResponseWriter, r *http.Request) {
+	if !h.handlePanic {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+	responseWriter := &statusCodeRecorder{ResponseWriter: w, buffer: new(bytes.Buffer)}
+	defer func() {
+		err := recover()
+		if err == nil {
+			return
+		}
+		if err == http.ErrAbortHandler {
+			// the server knows how to abort the response
+			panic(err)
+		}
+		count(h.metrics, MetricPanicsRecovered, 1, MetricLabels{Strategy: RecordingStrategy.String()})
+		// cannot change the response if headers were sent
+		if !responseWriter.wroteHeader {
+			h.panicResponse(responseWriter, r, err)
+		}
+		slog.Default().LogAttrs(r.Context(), slog.LevelError, fmt.Sprintf(h.messageFormat, "recovered from panic"),
+			slog.String("method", r.Method), slog.Any("url", r.URL), slog.Any("err", err), slog.String("stack", string(debug.Stack())))
+	}()
+	h.next.ServeHTTP(responseWriter.wrapped(), r)
+}
+
+// debugForced returns true if the Debug log records of the request must be written immediately.
+func (h RecallHandler) debugForced(r *http.Request) bool {
+	if DebugForced(r.Context()) {
+		return true
 	}
-	if fail {
-		rec.flush(ctx)
-		slog.Info(fmt.Sprintf(h.messageFormat, "HTTP request handling failed"), "method", r.Method,
-			"url", r.URL, "headers", h.filteredHeaders(r.Header), "payload", bodyReader.recorded(), "status", responseWriter.statusCode)
+	if h.debugCheck == nil {
+		return false
 	}
+	value := r.Header.Get(h.debugHeader)
+	return value != "" && h.debugCheck(r, value)
+}
+
+// recall flushes the recorded log records if the failure must be recalled.
+// Without a recorder, the debug log records were already written.
+func (h RecallHandler) recall(ctx context.Context, rec *recorder, info FailureInfo) {
+	if rec == nil {
+		return
+	}
+	if !rec.allow(info) {
+		rec.reset()
+		return
+	}
+	rec.waitForTasks()
+	rec.flush(ctx, info, true)
+}
+
+// allowRecall returns true if the deduplicator and budget allow a recall of the failure, and counts the outcome.
+func (h RecallHandler) allowRecall(info FailureInfo, labels MetricLabels) bool {
+	if !h.deduplicator.allow(info) {
+		count(h.metrics, MetricRecallsDeduplicated, 1, labels)
+		return false
+	}
+	if !h.budget.allow() {
+		count(h.metrics, MetricRecallsSuppressed, 1, labels)
+		return false
+	}
+	count(h.metrics, MetricRecalls, 1, labels)
+	return true
+}
+
+// exchange holds the state of handling a request.
+type exchange struct {
+	request  *http.Request // as passed to the next handler
+	body     *limitedBodyRecorder
+	response *statusCodeRecorder
+	start    time.Time
+}
+
+// failureAttrs returns the log attributes that describe the request and its response.
+func (h RecallHandler) failureAttrs(x exchange) []slog.Attr {
+	r, body, response := x.request, x.body, x.response
+	attrs := []slog.Attr{slog.String("method", r.Method), slog.Any("url", r.URL), slog.Any("headers", h.filteredHeaders(r.Header)),
+		{Key: "payload", Value: h.payload(r.Header, body.buffer.Bytes(), body.limit, body.bytesRead)}, slog.Int("status", response.statusCode)}
+	if h.responseCapacity > 0 {
+		attrs = append(attrs, slog.Any("response_headers", h.filteredHeaders(response.Header())),
+			slog.Attr{Key: "response_payload", Value: h.payload(response.Header(), response.buffer.Bytes(), response.limit, response.bytesWritten)})
+	}
+	if h.requestMetadata {
+		attrs = append(attrs, requestMetadata(x)...)
+	}
+	if h.requestAttrs != nil {
+		attrs = append(attrs, h.requestAttrs(r)...)
+	}
+	return attrs
+}
+
+// payload returns the log value of the recorded (decoded and redacted) bytes of a request or response body.
+func (h RecallHandler) payload(headers http.Header, data []byte, limit, total int) slog.Value {
+	truncated := len(data) < total
+	data, decodeTruncated := decodeContent(headers.Get("Content-Encoding"), data, limit)
+	if decodeTruncated {
+		// the decompressed size is unknown
+		truncated, limit, total = true, len(data), -1
+	}
+	if h.redactor != nil {
+		data = h.redactor.RedactPayload(headers.Get("Content-Type"), data)
+	}
+	return payloadValue(headers.Get("Content-Type"), data, truncated, limit, total)
 }
 
 func (h RecallHandler) filteredHeaders(headers http.Header) http.Header {
-	if h.headerFilter == nil {
-		return headers
+	if h.headerFilter != nil {
+		headers = h.headerFilter(headers)
+	}
+	if h.redactor != nil {
+		headers = h.redactor.RedactHeader(headers)
 	}
-	return h.headerFilter(headers)
+	return headers
 }
 
-type statusCodeRecorder struct {
-	http.ResponseWriter
-	statusCode int
+func statusOnlyPanicResponse(w http.ResponseWriter, r *http.Request, recovered any) {
+	w.WriteHeader(http.StatusInternalServerError)
 }
 
-func (h *statusCodeRecorder) WriteHeader(c int) {
-	h.statusCode = c
-	h.ResponseWriter.WriteHeader(c)
+// ProblemJSONPanicResponse writes an RFC 9457 problem details body with status 500 (Internal Server Error).
+// The recovered value is not exposed to the client.
+func ProblemJSONPanicResponse(w http.ResponseWriter, r *http.Request, recovered any) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(http.StatusInternalServerError)
+	fmt.Fprintf(w, `{"type":"about:blank","title":%q,"status":%d}`,
+		http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 }
 
 type limitedBodyRecorder struct {
-	body      io.ReadCloser
-	limit     int
-	buffer    *bytes.Buffer
-	bytesRead int
+	body       io.ReadCloser
+	limit      int
+	buffer     *bytes.Buffer
+	bytesRead  int
+	prefetched io.Reader // bytes that are already recorded
+}
+
+// prefetch reads and records up to maxBytes of the body before it is read by the handler.
+func (l *limitedBodyRecorder) prefetch(maxBytes int) {
+	data, _ := io.ReadAll(io.LimitReader(l.body, int64(maxBytes)))
+	l.buffer.Write(data)
+	l.bytesRead += len(data)
+	l.prefetched = bytes.NewReader(data)
 }
 
 func (l *limitedBodyRecorder) Read(p []byte) (n int, err error) {
+	if l.prefetched != nil {
+		n, err = l.prefetched.Read(p)
+		if err != io.EOF {
+			return
+		}
+		l.prefetched = nil
+		if n > 0 {
+			return n, nil
+		}
+	}
 	n, err = l.body.Read(p)
 	// write to buffer until hit limit
 	if size := l.buffer.Len(); size < l.limit {
@@ -153,9 +518,18 @@ func (l *limitedBodyRecorder) Close() error {
 	return l.body.Close()
 }
 func (l *limitedBodyRecorder) recorded() string {
-	s := l.buffer.String()
-	if len(l.buffer.Bytes()) < l.bytesRead {
-		s = fmt.Sprintf("%s..(%d of %d)", s, l.limit, l.bytesRead)
+	return recordedPayload(l.buffer.Bytes(), l.buffer.Len() < l.bytesRead, l.limit, l.bytesRead)
+}
+
+// recordedPayload returns the data as string with a suffix if it was truncated.
+func recordedPayload(data []byte, truncated bool, limit, total int) string {
+	s := string(data)
+	if truncated {
+		if total < 0 {
+			s = fmt.Sprintf("%s..(%d of more)", s, limit)
+		} else {
+			s = fmt.Sprintf("%s..(%d of %d)", s, limit, total)
+		}
 	}
 	return s
 }
@@ -3,8 +3,10 @@ package recall
 import (
 	"bytes"
 	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
@@ -101,3 +103,166 @@ func TestLimitedBodyRecorder(t *testing.T) {
 		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
 	}
 }
+
+func TestRecallHandlerResponseBodyCapture(t *testing.T) {
+	rec := new(recording)
+	old := slog.Default()
+	slog.SetDefault(slog.New(rec))
+	defer slog.SetDefault(old)
+
+	failing := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(500)
+		w.Write([]byte(`{"error":"database unavailable"}`))
+	})
+	h := NewRecallHandler(failing).WithResponseBodyCapture(10)
+	req, _ := http.NewRequest("GET", "/", nil)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	if len(rec.records) != 1 {
+		t.Fatalf("expected 1 record, got %d", len(rec.records))
+	}
+	attrs := map[string]slog.Value{}
+	for _, each := range attrsFrom(rec.records[0]) {
+		attrs[each.Key] = each.Value
+	}
+	if got, want := attrs["response_payload"].String(), `{"error":"..(10 of 32)`; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if got, want := attrs["response_headers"].Any().(http.Header).Get("Content-Type"), "application/json"; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
+
+func TestRecallHandlerPanicAfterWriteHeader(t *testing.T) {
+	panicking := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		panic("too late")
+	})
+	called := false
+	h := NewRecallHandler(panicking).WithPanicResponse(func(w http.ResponseWriter, r *http.Request, recovered any) {
+		called = true
+	})
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	h.ServeHTTP(rec, req)
+	if called {
+		t.Error("panic response must not be written after headers are sent")
+	}
+	if got, want := rec.Code, http.StatusAccepted; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
+
+func TestRecallHandlerPanicAbortHandler(t *testing.T) {
+	aborting := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	})
+	defer func() {
+		if err := recover(); err != http.ErrAbortHandler {
+			t.Errorf("expected ErrAbortHandler, got %v", err)
+		}
+	}()
+	req, _ := http.NewRequest("GET", "/", nil)
+	NewRecallHandler(aborting).ServeHTTP(httptest.NewRecorder(), req)
+}
+
+func TestRecallHandlerProblemJSONPanicResponse(t *testing.T) {
+	h := NewRecallHandler(erroringHandler{dopanic: true}).WithPanicResponse(ProblemJSONPanicResponse)
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", bytes.NewBufferString("test"))
+	h.ServeHTTP(rec, req)
+	if got, want := rec.Code, http.StatusInternalServerError; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if got, want := rec.Header().Get("Content-Type"), "application/problem+json"; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if got, want := rec.Body.String(), `{"type":"about:blank","title":"Internal Server Error","status":500}`; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
+
+func TestLimitedBodyRecorderPrefetch(t *testing.T) {
+	r := io.NopCloser(bytes.NewReader([]byte("prefetched body"))) // 15 bytes to read
+	l := &limitedBodyRecorder{body: r, limit: 4, buffer: new(bytes.Buffer)}
+	l.prefetch(4)
+	if got, want := l.recorded(), "pref"; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	all, _ := io.ReadAll(l)
+	if got, want := string(all), "prefetched body"; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if got, want := l.recorded(), "pref..(4 of 15)"; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
+
+func TestRecallHandlerEagerRequestBodyCapture(t *testing.T) {
+	rec := new(recording)
+	old := slog.Default()
+	slog.SetDefault(slog.New(rec))
+	defer slog.SetDefault(old)
+
+	// fails before reading the body
+	failing := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	h := NewRecallHandler(failing).WithEagerRequestBodyCapture(5)
+	req, _ := http.NewRequest("POST", "/", bytes.NewBufferString("unread payload"))
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	for _, each := range attrsFrom(rec.records[0]) {
+		if each.Key == "payload" {
+			if got, want := each.Value.String(), "unrea"; got != want {
+				t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+			}
+			return
+		}
+	}
+	t.Error("missing payload")
+}
+
+func TestRecallHandlerEagerRequestBodyCaptureDisabledForRoute(t *testing.T) {
+	rec := new(recording)
+	old := slog.Default()
+	slog.SetDefault(slog.New(rec))
+	defer slog.SetDefault(old)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /upload/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	h := NewRecallHandler(mux).WithEagerRequestBodyCapture(1024).
+		WithRoute("POST /upload/{id}", func(h RecallHandler) RecallHandler {
+			return h.WithRequestBodyCapture(0)
+		})
+	req, _ := http.NewRequest("POST", "/upload/1", bytes.NewBufferString("SECRET-FILE-CONTENT"))
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	for _, each := range attrsFrom(rec.records[0]) {
+		if strings.Contains(each.Value.String(), "SECRET") {
+			t.Errorf("unexpected payload in %s", each.Key)
+		}
+	}
+}
+
+func TestRecallHandlerDebugEnabledSkipsRecording(t *testing.T) {
+	old := slog.Default()
+	defer slog.SetDefault(old)
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	m := new(countingMetrics)
+	h := NewRecallHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Slog(r.Context()).Debug("live")
+		w.WriteHeader(http.StatusInternalServerError)
+	})).WithMetrics(m)
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if got, want := m.get("failures recording "), int64(1); got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if got, want := m.get("records_recorded recording "), int64(0); got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if got, want := m.get("recalls recording "), int64(0); got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"log/slog"
 	"runtime/debug"
 	"strings"
+	"time"
 )
 
 var logKey struct{ slog.Logger }
@@ -40,6 +41,18 @@ type Recaller struct {
 	captureStrategy captureStrategy
 	handlePanic     bool
 	errFilter       func(err error) bool // if this returns true then a recall will happen
+	redactor        *Redactor
+	recordHook      func(ctx context.Context, record slog.Record) slog.Record
+	flushHooks      []func(ctx context.Context, records []slog.Record)
+	logReplay       bool
+	sink            Sink
+	metrics         Metrics
+	budget          *RecallBudget
+	deduplicator    *Deduplicator
+	captureMode     captureMode
+	config          *Config
+	captureLevel    slog.Level
+	taskWait        time.Duration
 }
 
 // New creates a new Recaller initialized with a Context, default logger and default message format.
@@ -49,6 +62,9 @@ func New(ctx context.Context) Recaller {
 		messageFormat:   "[RECALL] %s",
 		captureStrategy: RecallOnErrorStrategy,
 		handlePanic:     true,
+		logReplay:       true,
+		captureLevel:    noCaptureLevel,
+		taskWait:        defaultTaskWait,
 	}
 }
 
@@ -83,10 +99,108 @@ func (r Recaller) WithErrorFilter(filter func(err error) bool) Recaller {
 	return r
 }
 
+// WithRedactor sets the Redactor to mask sensitive information in the attributes of recalled log records.
+// See NewRedactor for the defaults.
+func (r Recaller) WithRedactor(red Redactor) Recaller {
+	r.redactor = &red
+	return r
+}
+
+// WithRecordHook sets the function that can change a log record before it is recorded, e.g. to add trace information.
+// The context is the one passed to the logger or the Recaller context if the record was logged without one.
+// Only used by the RecordingStrategy.
+func (r Recaller) WithRecordHook(hook func(ctx context.Context, record slog.Record) slog.Record) Recaller {
+	r.recordHook = hook
+	return r
+}
+
+// WithFlushHook adds a function that is called with the recorded log records after these are written on failure.
+// Only used by the RecordingStrategy.
+func (r Recaller) WithFlushHook(hook func(ctx context.Context, records []slog.Record)) Recaller {
+	r.flushHooks = append(r.flushHooks[:len(r.flushHooks):len(r.flushHooks)], hook)
+	return r
+}
+
+// WithLogReplay enables or disables writing the recorded log records to the sink on failure. Default is true.
+// Disable it if the records are only needed by a flush hook, e.g. to add them to a trace.
+// Only used by the RecordingStrategy.
+func (r Recaller) WithLogReplay(enabled bool) Recaller {
+	r.logReplay = enabled
+	return r
+}
+
+// WithSink sets the Sink to write the recorded log records to on failure.
+// Default is a HandlerSink using the handler of the default logger.
+// The default sink is created for each flush ; to set its fallback handler or error function, use e.g.
+// WithSink(NewHandlerSink(nil, "[RECALL] %s").WithFallback(h)).
+// Only used by the RecordingStrategy.
+func (r Recaller) WithSink(s Sink) Recaller {
+	r.sink = s
+	return r
+}
+
+// WithCaptureMode sets how log records are captured by the RecordingStrategy. Default is RecordsCaptureMode.
+// Use SnapshotCaptureMode to write the values as they were when logged ; use EncodedCaptureMode to reduce memory.
+func (r Recaller) WithCaptureMode(mode captureMode) Recaller {
+	r.captureMode = mode
+	return r
+}
+
+// WithTaskWait sets the maximum time to wait for goroutines, started with Go or GroupGo, to finish before the recorded log records are written.
+// Default is one second. Only used by the RecordingStrategy.
+func (r Recaller) WithTaskWait(d time.Duration) Recaller {
+	r.taskWait = d
+	return r
+}
+
+// WithMetrics sets the receiver of counts of recall activity, such as an ExpvarMetrics.
+func (r Recaller) WithMetrics(m Metrics) Recaller {
+	r.metrics = m
+	return r
+}
+
+// WithBudget sets the RecallBudget that limits the number of recalls ; share it among Recallers and RecallHandlers.
+// Without a budget, every failure causes a recall.
+func (r Recaller) WithBudget(b *RecallBudget) Recaller {
+	r.budget = b
+	return r
+}
+
+// WithDeduplicator sets the Deduplicator that limits the recalls of identical recurring failures ; share it among Recallers and RecallHandlers.
+// Without a deduplicator, every failure causes a recall.
+func (r Recaller) WithDeduplicator(d *Deduplicator) Recaller {
+	r.deduplicator = d
+	return r
+}
+
+// WithConfig sets the Config with settings that can be changed at runtime ; these override the settings of the Recaller if set.
+func (r Recaller) WithConfig(c *Config) Recaller {
+	r.config = c
+	return r
+}
+
+// count adds one to the metric, labelled with the strategy.
+func (r Recaller) count(m Metric) {
+	count(r.metrics, m, 1, MetricLabels{Strategy: r.captureStrategy.String()})
+}
+
 // Call calls the function and produces debug log messages when the function returns an error.
 // Depending on the capture strategy, the function is called once or twice.
 // The default strategy is to call the function a second time when an error is returned.
+// If the context is marked with ForceDebug then the function is called once and Debug logs are written immediately.
 func (r Recaller) Call(f func(ctx context.Context) error) error {
+	if r.config != nil {
+		if !r.config.Enabled() {
+			// no recall but keep panic recovery
+			return r.callWithoutRecording(Slog(r.context), f)
+		}
+		r = r.config.applyTo(r)
+	}
+	r.count(MetricCalls)
+	if DebugForced(r.context) {
+		handler := debugHandler{Slog(r.context).Handler(), r.messageFormat, r.redactor}
+		return r.callWithoutRecording(slog.New(handler), f)
+	}
 	if r.captureStrategy == RecordingStrategy {
 		return r.captureRecords(f)
 	}
@@ -100,17 +214,34 @@ func (r Recaller) captureStrategyRecallOnError(f func(ctx context.Context) error
 	// is debug enabled?
 	if currentLogger.Handler().Enabled(r.context, slog.LevelDebug) {
 		// no recall on error needed
-		return f(r.context)
+		err := f(r.context)
+		if err != nil {
+			r.count(MetricFailures)
+		}
+		return err
 	}
 	if r.handlePanic {
 		defer func() {
 			// recover from first panic
 			err := recover()
 			if err != nil {
+				r.count(MetricPanicsRecovered)
+				r.count(MetricFailures)
+				release, ok := r.acquireRerun(FailureInfo{Recovered: err})
+				if !ok {
+					currentLogger.Error(fmt.Sprintf(r.messageFormat, "recovered from panic"),
+						"err", err, "stack", string(debug.Stack()))
+					callErr = fmt.Errorf("%v", err)
+					return
+				}
+				defer release()
+				r.count(MetricRecalls)
+				r.count(MetricReruns)
 				defer func() {
 					// recover from second panic
 					secondErr := recover()
 					if secondErr != nil {
+						r.count(MetricPanicsRecovered)
 						currentLogger.Error(fmt.Sprintf(r.messageFormat, "recovered from panic"),
 							"err", err, "stack", string(debug.Stack()))
 						callErr = fmt.Errorf("%v", secondErr)
@@ -123,10 +254,19 @@ func (r Recaller) captureStrategyRecallOnError(f func(ctx context.Context) error
 	}
 	err := f(r.context)
 	if err != nil {
+		r.count(MetricFailures)
 		// check if error passes the filter
 		if r.errFilter != nil && !r.errFilter(err) {
+			r.count(MetricErrorsFiltered)
+			return err
+		}
+		release, ok := r.acquireRerun(FailureInfo{Err: err})
+		if !ok {
 			return err
 		}
+		defer release()
+		r.count(MetricRecalls)
+		r.count(MetricReruns)
 		// second time return value could be nil
 		err = r.callWithDebugLogging(f)
 	}
@@ -135,17 +275,58 @@ func (r Recaller) captureStrategyRecallOnError(f func(ctx context.Context) error
 
 func (r Recaller) callWithDebugLogging(f func(ctx context.Context) error) error {
 	currentLogger := Slog(r.context)
-	handler := debugHandler{currentLogger.Handler(), r.messageFormat}
+	handler := debugHandler{currentLogger.Handler(), r.messageFormat, r.redactor}
 	debugLogger := slog.New(handler)
 	ctx := ContextWithLogger(r.context, debugLogger)
 	return f(ctx)
 }
 
+// callWithoutRecording calls the function with a context that has the logger.
+func (r Recaller) callWithoutRecording(log *slog.Logger, f func(ctx context.Context) error) (callErr error) {
+	if r.handlePanic {
+		defer func() {
+			err := recover()
+			if err != nil {
+				r.count(MetricPanicsRecovered)
+				r.count(MetricFailures)
+				log.Error(fmt.Sprintf(r.messageFormat, "recovered from panic"),
+					"err", err, "stack", string(debug.Stack()))
+				callErr = fmt.Errorf("%v", err)
+			}
+		}()
+	}
+	err := f(ContextWithLogger(r.context, log))
+	if err != nil {
+		r.count(MetricFailures)
+	}
+	return err
+}
+
 // captureRecords call the function and records all non-handled log messages.
 // If the function returns an error then the recorded messages are replayed.
 func (r Recaller) captureRecords(f func(ctx context.Context) error) (callErr error) {
 	def := slog.Default()
+	// is debug enabled?
+	if def.Handler().Enabled(r.context, slog.LevelDebug) {
+		// no recording needed
+		return r.callWithoutRecording(def, f)
+	}
 	rec := newRecorder(def.Handler(), r.messageFormat)
+	defer rec.release()
+	rec.redactor = r.redactor
+	rec.ctx = r.context
+	rec.recordHook = r.recordHook
+	rec.flushHooks = r.flushHooks
+	rec.logReplay = r.logReplay
+	rec.sink = r.sink
+	rec.mode = r.captureMode
+	rec.minLevel = r.captureLevel
+	rec.taskWait = r.taskWait
+	if r.deduplicator != nil || r.budget != nil || r.metrics != nil {
+		rec.allowRecall = r.allowRecall
+	}
+	rec.metrics = r.metrics
+	rec.labels = MetricLabels{Strategy: r.captureStrategy.String()}
 	log := slog.New(rec)
 	ctx := ContextWithLogger(r.context, log)
 	if r.handlePanic {
@@ -153,7 +334,9 @@ func (r Recaller) captureRecords(f func(ctx context.Context) error) (callErr err
 			// recover from first panic
 			err := recover()
 			if err != nil {
-				rec.flush(ctx)
+				r.count(MetricPanicsRecovered)
+				r.count(MetricFailures)
+				r.recall(ctx, rec, FailureInfo{Recovered: err})
 				log.Error(fmt.Sprintf(r.messageFormat, "recovered from panic"),
 					"err", err, "stack", string(debug.Stack()))
 				callErr = fmt.Errorf("%v", err)
@@ -162,12 +345,53 @@ func (r Recaller) captureRecords(f func(ctx context.Context) error) (callErr err
 	}
 	err := f(ctx)
 	if err != nil {
+		r.count(MetricFailures)
 		// check if error passes the filter
 		if r.errFilter != nil && !r.errFilter(err) {
+			r.count(MetricErrorsFiltered)
 			rec.reset()
 			return err
 		}
-		rec.flush(ctx)
+		r.recall(ctx, rec, FailureInfo{Err: err})
+		return err
 	}
-	return err
+	rec.reset()
+	return nil
+}
+
+// acquireRerun returns true if the failure must be recalled by a rerun ; release must be called after the rerun.
+func (r Recaller) acquireRerun(info FailureInfo) (release func(), ok bool) {
+	if !r.deduplicator.allow(info) {
+		r.count(MetricRecallsDeduplicated)
+		return nil, false
+	}
+	release, ok = r.budget.acquireRerun()
+	if !ok {
+		r.count(MetricRecallsSuppressed)
+	}
+	return
+}
+
+// recall flushes the recorded log records if the failure must be recalled.
+func (r Recaller) recall(ctx context.Context, rec *recorder, info FailureInfo) {
+	if !rec.allow(info) {
+		rec.reset()
+		return
+	}
+	rec.waitForTasks()
+	rec.flush(ctx, info, true)
+}
+
+// allowRecall returns true if the deduplicator and budget allow a recall of the failure, and counts the outcome.
+func (r Recaller) allowRecall(info FailureInfo) bool {
+	if !r.deduplicator.allow(info) {
+		r.count(MetricRecallsDeduplicated)
+		return false
+	}
+	if !r.budget.allow() {
+		r.count(MetricRecallsSuppressed)
+		return false
+	}
+	r.count(MetricRecalls)
+	return true
 }
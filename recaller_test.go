@@ -2,6 +2,8 @@ package recall
 
 import (
 	"context"
+	"fmt"
+	"io"
 	"log/slog"
 	"strings"
 	"testing"
@@ -212,3 +214,80 @@ func (r *recording) WithAttrs(attrs []slog.Attr) slog.Handler {
 func (r *recording) WithGroup(group string) slog.Handler {
 	return r
 }
+
+func TestRecallRecordingHooks(t *testing.T) {
+	type key struct{}
+	rec := new(recording)
+	old := slog.Default()
+	slog.SetDefault(slog.New(rec))
+	defer slog.SetDefault(old)
+	ctx := context.WithValue(context.Background(), key{}, "call")
+	var contexts []any
+	var flushed []slog.Record
+	r := New(ctx).WithCaptureStrategy(RecordingStrategy).
+		WithRecordHook(func(ctx context.Context, record slog.Record) slog.Record {
+			contexts = append(contexts, ctx.Value(key{}))
+			return record
+		}).
+		WithFlushHook(func(ctx context.Context, records []slog.Record) {
+			flushed = records
+		})
+	r.Call(func(ctx context.Context) error {
+		Slog(ctx).Debug("without context")
+		Slog(ctx).DebugContext(context.WithValue(ctx, key{}, "log"), "with context")
+		return errors.New("error")
+	})
+	if got, want := fmt.Sprint(contexts), "[call log]"; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if got, want := len(flushed), 2; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
+
+func TestRecallRecordingWithoutLogReplay(t *testing.T) {
+	rec := new(recording)
+	old := slog.Default()
+	slog.SetDefault(slog.New(rec))
+	defer slog.SetDefault(old)
+	flushed := 0
+	r := New(context.Background()).WithCaptureStrategy(RecordingStrategy).WithLogReplay(false).
+		WithFlushHook(func(ctx context.Context, records []slog.Record) {
+			flushed += len(records)
+		})
+	r.Call(willError)
+	if got, want := len(rec.records), 0; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if got, want := flushed, 1; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
+
+func TestRecallRecordingDebugEnabledSkipsRecording(t *testing.T) {
+	old := slog.Default()
+	defer slog.SetDefault(old)
+	level := new(slog.LevelVar)
+	level.Set(slog.LevelDebug)
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: level})))
+	m := new(countingMetrics)
+	hooked := 0
+	r := New(context.Background()).WithCaptureStrategy(RecordingStrategy).WithMetrics(m).
+		WithRecordHook(func(ctx context.Context, record slog.Record) slog.Record {
+			hooked++
+			return record
+		})
+	r.Call(willError)
+	if got, want := hooked, 0; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if got, want := m.get("recalls recording "), int64(0); got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	// level changed at runtime
+	level.Set(slog.LevelInfo)
+	r.Call(willError)
+	if got, want := m.get("recalls recording "), int64(1); got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
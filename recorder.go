@@ -1,19 +1,78 @@
 package recall
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
-	"os"
 	"sync"
 	"time"
 )
 
+// recordsPool holds the record buffers of recorders that completed without a flush.
+var recordsPool = sync.Pool{
+	New: func() any {
+		buf := make([]slog.Record, 0, 16)
+		return &buf
+	},
+}
+
+// encodedRecords holds JSON encoded records.
+type encodedRecords struct {
+	buffer  bytes.Buffer
+	encoder slog.Handler // writes to buffer
+	pcs     []uintptr    // program counter of each record, which is not encoded
+}
+
+// encodedPool holds the encoded record buffers of recorders that completed without a flush.
+var encodedPool = sync.Pool{
+	New: func() any {
+		e := new(encodedRecords)
+		e.encoder = slog.NewJSONHandler(&e.buffer, nil)
+		return e
+	},
+}
+
+// LateKey is the attribute key that marks a log record that was logged after a failed call or request has completed,
+// e.g. by a goroutine that was not started with Go.
+const LateKey = "recall.late"
+
+// defaultTaskWait is the maximum time to wait for goroutines started by Go or GroupGo before writing the records.
+const defaultTaskWait = time.Second
+
+// maxPooledRecords is the capacity above which a record buffer is not returned to the pool.
+const maxPooledRecords = 1024
+
+// maxPooledEncodedBytes is the capacity above which an encoded records buffer is not returned to the pool.
+const maxPooledEncodedBytes = 64 << 10
+
 type recorder struct {
-	mux           *sync.RWMutex
+	mux           sync.RWMutex
 	handler       slog.Handler
 	records       []slog.Record
 	messageFormat string
+	redactor      *Redactor
+	ctx           context.Context // used by the record hook if a record is logged without context
+	recordHook    func(ctx context.Context, record slog.Record) slog.Record
+	flushHooks    []func(ctx context.Context, records []slog.Record)
+	logReplay     bool
+	sink          Sink // nil means a HandlerSink using the handler
+	metrics       Metrics
+	labels        MetricLabels
+	mode          captureMode
+	encoded       *encodedRecords // used by EncodedCaptureMode
+	encodedCount  int
+	minLevel      slog.Level    // records below are discarded
+	running       int           // goroutines started by Go or GroupGo that have not finished
+	idle          chan struct{} // closed when running becomes zero
+	taskCount     int
+	taskWait      time.Duration
+	closed        bool                        // the call or request has completed or its records were written
+	recalled      bool                        // the records were flushed at least once
+	allowRecall   func(info FailureInfo) bool // nil allows all
+	decide        sync.Once                   // allowRecall is called once per call or request
+	allowed       bool
 }
 
 type subRecorder struct {
@@ -49,8 +108,10 @@ func (r subRecorder) WithGroup(group string) slog.Handler {
 func newRecorder(handler slog.Handler, format string) *recorder {
 	return &recorder{
 		handler:       handler,
-		mux:           new(sync.RWMutex),
 		messageFormat: format,
+		logReplay:     true,
+		minLevel:      noCaptureLevel,
+		taskWait:      defaultTaskWait,
 	}
 }
 
@@ -61,18 +122,87 @@ func (r *recorder) Enabled(ctx context.Context, level slog.Level) bool {
 
 func (r *recorder) Handle(ctx context.Context, record slog.Record) error {
 	if record.Level == slog.LevelError {
-		r.flush(ctx)
+		if r.recorded() > 0 {
+			// the message identifies the failure for a deduplicator
+			info := FailureInfo{Time: record.Time, Err: errors.New(record.Message)}
+			if r.allow(info) {
+				r.flush(ctx, info, false)
+			} else {
+				r.reset()
+			}
+		}
 		return r.handler.Handle(ctx, record)
 	}
 	// only record those which are not enabled
 	if !r.handler.Enabled(ctx, record.Level) {
+		if record.Level < r.minLevel {
+			return nil
+		}
+		r.mux.RLock()
+		closed := r.closed
+		r.mux.RUnlock()
+		if closed {
+			return r.handleLate(ctx, record)
+		}
+		if r.recordHook != nil {
+			if ctx == context.Background() && r.ctx != nil {
+				ctx = r.ctx
+			}
+			record = r.recordHook(ctx, record)
+		}
+		if r.mode == SnapshotCaptureMode {
+			record = snapshot(record)
+		}
 		r.mux.Lock()
-		r.records = append(r.records, record)
+		// check again because the recorder may have been closed in the meantime
+		if r.closed {
+			r.mux.Unlock()
+			return r.handleLate(ctx, record)
+		}
+		var err error
+		if r.mode == EncodedCaptureMode {
+			err = r.encode(ctx, record)
+		} else {
+			if r.records == nil {
+				r.records = (*recordsPool.Get().(*[]slog.Record))[:0]
+			}
+			r.records = append(r.records, record)
+		}
 		r.mux.Unlock()
+		if err != nil {
+			return err
+		}
+		count(r.metrics, MetricRecordsRecorded, 1, r.labels)
 		return nil
 	}
 	return r.handler.Handle(ctx, record)
 }
+
+// allow returns true if the failure may be recalled. The first failure of a call or request decides.
+func (r *recorder) allow(info FailureInfo) bool {
+	r.decide.Do(func() {
+		r.allowed = r.allowRecall == nil || r.allowRecall(info)
+	})
+	return r.allowed
+}
+
+// encode writes the record as JSON to the encoded buffer. Must be called with the lock held.
+func (r *recorder) encode(ctx context.Context, record slog.Record) error {
+	if r.encoded == nil {
+		r.encoded = encodedPool.Get().(*encodedRecords)
+	}
+	r.encodedCount++
+	r.encoded.pcs = append(r.encoded.pcs, record.PC)
+	return r.encoded.encoder.Handle(ctx, record)
+}
+
+// recorded returns the number of recorded records.
+func (r *recorder) recorded() int {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+	return len(r.records) + r.encodedCount
+}
+
 func (r *recorder) WithAttrs(attrs []slog.Attr) slog.Handler {
 	return subRecorder{attrs: attrs, root: r}
 }
@@ -80,30 +210,124 @@ func (r *recorder) WithGroup(group string) slog.Handler {
 	return subRecorder{root: r, group: group}
 }
 
+// reset discards the recorded records.
 func (r *recorder) reset() {
 	r.mux.Lock()
 	defer r.mux.Unlock()
-	r.records = []slog.Record{}
+	count(r.metrics, MetricRecordsDropped, int64(len(r.records)+r.encodedCount), r.labels)
+	// clear to release the references held by the attributes
+	clear(r.records)
+	r.records = r.records[:0]
+	r.resetEncoded()
+}
+
+// resetEncoded discards the encoded records. Must be called with the lock held.
+func (r *recorder) resetEncoded() {
+	if r.encoded != nil {
+		r.encoded.buffer.Reset()
+		r.encoded.pcs = r.encoded.pcs[:0]
+	}
+	r.encodedCount = 0
 }
 
-func (r *recorder) flush(ctx context.Context) {
+// handleLate handles a record that is logged after the call or request has completed.
+// If the records were written then the late record is written too, marked with LateKey ; otherwise it is discarded.
+func (r *recorder) handleLate(ctx context.Context, record slog.Record) error {
+	count(r.metrics, MetricRecordsLate, 1, r.labels)
+	r.mux.RLock()
+	recalled := r.recalled
+	r.mux.RUnlock()
+	if !recalled {
+		return nil
+	}
+	record = record.Clone()
+	record.AddAttrs(slog.Bool(LateKey, true))
+	return debugHandler{r.handler, r.messageFormat, r.redactor}.Handle(ctx, record)
+}
+
+// release returns the record buffer to the pool and closes the recorder ; records that are logged after that are handled as late.
+func (r *recorder) release() {
 	r.mux.Lock()
 	defer r.mux.Unlock()
-	for _, record := range r.records {
-		if record.Level == slog.LevelDebug {
-			record.Message = fmt.Sprintf(r.messageFormat, record.Message)
-			// change level otherwise it will be filtered out
-			record.Level = slog.LevelInfo
+	r.closed = true
+	if r.encoded != nil {
+		count(r.metrics, MetricRecordsDropped, int64(r.encodedCount), r.labels)
+		r.resetEncoded()
+		if r.encoded.buffer.Cap() <= maxPooledEncodedBytes {
+			encodedPool.Put(r.encoded)
+		}
+		r.encoded = nil
+	}
+	if r.records == nil {
+		return
+	}
+	count(r.metrics, MetricRecordsDropped, int64(len(r.records)), r.labels)
+	// clear to release the references held by the attributes
+	clear(r.records)
+	if cap(r.records) <= maxPooledRecords {
+		buf := r.records[:0]
+		recordsPool.Put(&buf)
+	}
+	r.records = nil
+}
+
+// flush writes the recorded records to the sink (unless log replay is disabled) and calls the flush hooks.
+// If final then the recorder is closed ; records that are logged after that, e.g. by a task that did not finish in time, are handled as late.
+// The lock is not held while writing so that sinks and hooks can log using the context.
+func (r *recorder) flush(ctx context.Context, info FailureInfo, final bool) {
+	if info.Time.IsZero() {
+		info.Time = time.Now()
+	}
+	records, recalled, err := r.take(final)
+	if err != nil {
+		failure := slog.NewRecord(time.Now(), slog.LevelError, fmt.Sprintf(r.messageFormat, "decoding records failed"), 0)
+		failure.AddAttrs(slog.String("err", err.Error()))
+		_ = stderrFallback.Handle(ctx, failure)
+	}
+	if recalled && len(records) == 0 {
+		// nothing was recorded since the failure was written
+		return
+	}
+	if r.redactor != nil {
+		for i, record := range records {
+			records[i] = r.redactor.redactRecord(record)
 		}
-		if err := r.handler.Handle(ctx, record); err != nil {
-			// do not loose the record so print it directly
-			_, _ = fmt.Fprintf(os.Stderr, "%v %v %s", record.Time.Format(time.RFC3339), record.Level, record.Message)
-			record.Attrs(func(a slog.Attr) bool {
-				_, _ = fmt.Fprintf(os.Stderr, " %s=%v", a.Key, a.Value)
-				return true
-			})
-			fmt.Fprintln(os.Stderr)
+	}
+	if r.logReplay {
+		sink := r.sink
+		if sink == nil {
+			sink = NewHandlerSink(r.handler, r.messageFormat)
+		}
+		if err := sink.Flush(contextWithSinkMetrics(ctx, r.metrics, r.labels), info, records); err != nil {
+			failure := slog.NewRecord(time.Now(), slog.LevelError, fmt.Sprintf(r.messageFormat, "sink failed"), 0)
+			failure.AddAttrs(slog.String("err", err.Error()), slog.Int("records", len(records)))
+			_ = stderrFallback.Handle(ctx, failure)
 		}
 	}
-	r.records = []slog.Record{}
+	count(r.metrics, MetricRecordsFlushed, int64(len(records)), r.labels)
+	if len(records) > 0 {
+		for _, each := range r.flushHooks {
+			each(ctx, records)
+		}
+	}
+}
+
+// take removes the recorded records, decoding these if needed, and marks the recorder as recalled.
+// It also returns whether the recorder was already recalled.
+func (r *recorder) take(final bool) (records []slog.Record, recalled bool, err error) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	recalled = r.recalled
+	records = r.records
+	if r.encodedCount > 0 {
+		var decoded []slog.Record
+		decoded, err = decodeRecords(r.encoded.buffer.Bytes(), r.encoded.pcs)
+		records = append(records, decoded...)
+		r.resetEncoded()
+	}
+	// sinks and hooks may retain the records so the buffer is not reused
+	r.records = nil
+	r.recalled = true
+	r.closed = r.closed || final
+	return records, recalled, err
 }
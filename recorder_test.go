@@ -3,8 +3,12 @@ package recall
 import (
 	"context"
 	"fmt"
+	"io"
 	"log/slog"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"sync"
 	"testing"
 )
 
@@ -48,7 +52,7 @@ func TestRecorder(t *testing.T) {
 	if all[0].NumAttrs() != 1 {
 		t.Errorf("expected 1 attribute, got %d", all[0].NumAttrs())
 	}
-	rec.flush(context.TODO())
+	rec.flush(context.TODO(), FailureInfo{}, true)
 	if len(rec.records) != 0 {
 		t.Fail()
 	}
@@ -144,3 +148,229 @@ func TestErrornousDefaultHandler(t *testing.T) {
 	log.Debug("test", "q", 42)
 	log.Error("test")
 }
+
+func BenchmarkRecallerRecording(b *testing.B) {
+	r := New(context.Background()).WithCaptureStrategy(RecordingStrategy)
+	f := func(ctx context.Context) error {
+		log := Slog(ctx)
+		for i := range 10 {
+			log.Debug("step", "i", i)
+		}
+		return nil
+	}
+	b.ReportAllocs()
+	for range b.N {
+		r.Call(f)
+	}
+}
+
+func BenchmarkRecallHandlerRecording(b *testing.B) {
+	h := NewRecallHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log := Slog(r.Context())
+		for i := range 10 {
+			log.Debug("step", "i", i)
+		}
+	}))
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/", nil)
+	b.ReportAllocs()
+	for range b.N {
+		h.ServeHTTP(w, r)
+	}
+}
+
+func TestRecorderReleaseClearsRecords(t *testing.T) {
+	rec := newRecorder(slog.Default().Handler(), "%s")
+	log := slog.New(rec)
+	log.Debug("pooled", "secret", "value")
+	buf := rec.records
+	rec.release()
+	if rec.records != nil {
+		t.Error("expected no records after release")
+	}
+	if got, want := buf[:1][0].Message, ""; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	// recorder is closed
+	log.Debug("late")
+	if got, want := len(rec.records), 0; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
+
+func TestRecorderFlushDoesNotReuseRecords(t *testing.T) {
+	var retained []slog.Record
+	rec := newRecorder(slog.Default().Handler(), "%s")
+	rec.logReplay = false
+	rec.flushHooks = append(rec.flushHooks, func(ctx context.Context, records []slog.Record) {
+		retained = records
+	})
+	log := slog.New(rec)
+	log.Debug("kept")
+	rec.flush(context.Background(), FailureInfo{}, true)
+	log.Debug("next")
+	rec.release()
+	log.Debug("late")
+	if got, want := retained[0].Message, "kept"; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
+
+func BenchmarkRecallerCaptureModes(b *testing.B) {
+	for _, each := range []struct {
+		name string
+		mode captureMode
+	}{
+		{"records", RecordsCaptureMode},
+		{"snapshot", SnapshotCaptureMode},
+		{"encoded", EncodedCaptureMode},
+	} {
+		b.Run(each.name, func(b *testing.B) {
+			r := New(context.Background()).WithCaptureStrategy(RecordingStrategy).WithCaptureMode(each.mode)
+			f := func(ctx context.Context) error {
+				log := Slog(ctx)
+				for i := range 10 {
+					log.Debug("step", "i", i, "name", "recall", "err", os.ErrNotExist)
+				}
+				return nil
+			}
+			b.ReportAllocs()
+			for range b.N {
+				r.Call(f)
+			}
+		})
+	}
+}
+
+func BenchmarkRecallerRecordingLevels(b *testing.B) {
+	old := slog.Default()
+	defer slog.SetDefault(old)
+	level := new(slog.LevelVar)
+	slog.SetDefault(slog.New(slog.NewJSONHandler(io.Discard, &slog.HandlerOptions{Level: level})))
+	r := New(context.Background()).WithCaptureStrategy(RecordingStrategy)
+	f := func(ctx context.Context) error {
+		log := Slog(ctx)
+		for i := range 10 {
+			log.Debug("step", "i", i)
+		}
+		return nil
+	}
+	for _, each := range []slog.Level{slog.LevelInfo, slog.LevelDebug} {
+		b.Run(each.String(), func(b *testing.B) {
+			level.Set(each)
+			b.ReportAllocs()
+			for range b.N {
+				r.Call(f)
+			}
+		})
+	}
+}
+
+func BenchmarkRecallHandlerRecordingLevels(b *testing.B) {
+	old := slog.Default()
+	defer slog.SetDefault(old)
+	level := new(slog.LevelVar)
+	slog.SetDefault(slog.New(slog.NewJSONHandler(io.Discard, &slog.HandlerOptions{Level: level})))
+	h := NewRecallHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log := Slog(r.Context())
+		for i := range 10 {
+			log.Debug("step", "i", i)
+		}
+	}))
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/", nil)
+	for _, each := range []slog.Level{slog.LevelInfo, slog.LevelDebug} {
+		b.Run(each.String(), func(b *testing.B) {
+			level.Set(each)
+			b.ReportAllocs()
+			for range b.N {
+				h.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+func TestRecorderLateRecords(t *testing.T) {
+	rec := new(recording)
+	old := slog.Default()
+	slog.SetDefault(slog.New(rec))
+	defer slog.SetDefault(old)
+	m := new(countingMetrics)
+	r := New(context.Background()).WithCaptureStrategy(RecordingStrategy).WithMetrics(m)
+	var leaked *slog.Logger
+	r.Call(func(ctx context.Context) error {
+		leaked = Slog(ctx)
+		return nil
+	})
+	leaked.Debug("late after success")
+	if got, want := len(rec.records), 0; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	r.Call(func(ctx context.Context) error {
+		leaked = Slog(ctx)
+		return willError(ctx)
+	})
+	before := len(rec.records)
+	leaked.Debug("late after failure")
+	leaked.Info("enabled")
+	if got, want := len(rec.records), before+2; got != want {
+		t.Fatalf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	late := rec.records[before]
+	if got, want := late.Message, "[RECALL] late after failure"; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if got, want := attrsFrom(late)[0].Key, LateKey; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if got, want := m.get("records_late recording "), int64(2); got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
+
+func TestRecorderReleaseWhileLogging(t *testing.T) {
+	for range 1000 {
+		m := new(countingMetrics)
+		rec := newRecorder(slog.Default().Handler(), "%s")
+		rec.metrics = m
+		log := slog.New(rec)
+		var wg sync.WaitGroup
+		for range 4 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for range 10 {
+					log.Debug("racing")
+				}
+			}()
+		}
+		rec.release()
+		wg.Wait()
+		recorded, dropped, late := m.get("records_recorded  "), m.get("records_dropped  "), m.get("records_late  ")
+		if recorded != dropped || recorded+late != 40 {
+			t.Fatalf("recorded=%d dropped=%d late=%d", recorded, dropped, late)
+		}
+	}
+}
+
+func TestRecorderFinalFlushClosesRecorder(t *testing.T) {
+	rec := new(recording)
+	m := new(countingMetrics)
+	r := newRecorder(rec, "[RECALL] %s")
+	r.metrics = m
+	log := slog.New(r)
+	log.Debug("before")
+	r.flush(context.Background(), FailureInfo{}, true)
+	// e.g. by a task that did not finish in time
+	log.Debug("after")
+	r.release()
+	if got, want := len(rec.records), 2; got != want {
+		t.Fatalf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if got, want := rec.records[1].Message, "[RECALL] after"; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if got, want := m.get("records_dropped  "), int64(0); got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
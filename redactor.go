@@ -0,0 +1,228 @@
+package recall
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Redactor masks sensitive information in HTTP headers, request and response payloads and log attributes.
+// Attributes of recorded log records are only masked when these are flushed.
+type Redactor struct {
+	mask        string
+	headers     []string
+	jsonPaths   []jsonPath
+	formFields  []string
+	keyPatterns []string
+}
+
+// jsonPath is a path of keys in a JSON document with the pattern for masking its value in a payload that cannot be parsed.
+type jsonPath struct {
+	keys     []string
+	fallback *regexp.Regexp // nil if the last key is "*"
+}
+
+// NewRedactor returns a Redactor that masks the headers Authorization, Cookie, Set-Cookie and X-Api-Key,
+// the JSON field and form field "password" and attributes with a key that matches *password*, *token* or *secret*.
+func NewRedactor() Redactor {
+	return Redactor{mask: "***"}.
+		WithHeaders("Authorization", "Cookie", "Set-Cookie", "X-Api-Key").
+		WithJSONPaths("$.password").
+		WithFormFields("password").
+		WithAttrKeyPatterns("*password*", "*token*", "*secret*")
+}
+
+// WithMask sets the replacement for sensitive values. Default is "***".
+func (r Redactor) WithMask(mask string) Redactor {
+	r.mask = mask
+	return r
+}
+
+// WithHeaders adds the names of headers for which the values must be masked.
+func (r Redactor) WithHeaders(names ...string) Redactor {
+	r.headers = r.headers[:len(r.headers):len(r.headers)]
+	for _, each := range names {
+		r.headers = append(r.headers, http.CanonicalHeaderKey(each))
+	}
+	return r
+}
+
+// WithJSONPaths adds the paths of fields in a JSON payload for which the values must be masked.
+// A path is a dot separated list of object keys, optionally starting with "$.".
+// Use "*" to match any key or array element, e.g. "$.users.*.password".
+func (r Redactor) WithJSONPaths(paths ...string) Redactor {
+	r.jsonPaths = r.jsonPaths[:len(r.jsonPaths):len(r.jsonPaths)]
+	for _, each := range paths {
+		p := jsonPath{keys: strings.Split(strings.TrimPrefix(each, "$."), ".")}
+		if key := p.keys[len(p.keys)-1]; key != "*" {
+			// matches the string value of the last key
+			p.fallback = regexp.MustCompile(`("` + regexp.QuoteMeta(key) + `"\s*:\s*)"(?:[^"\\]|\\.)*("|$)`)
+		}
+		r.jsonPaths = append(r.jsonPaths, p)
+	}
+	return r
+}
+
+// WithFormFields adds the names of fields in a form (application/x-www-form-urlencoded) payload for which the values must be masked.
+func (r Redactor) WithFormFields(names ...string) Redactor {
+	r.formFields = append(r.formFields[:len(r.formFields):len(r.formFields)], names...)
+	return r
+}
+
+// WithAttrKeyPatterns adds patterns (see path.Match) for keys of log attributes for which the values must be masked.
+// Matching is case-insensitive and uses the full key of an attribute in a group, e.g. "user.password".
+func (r Redactor) WithAttrKeyPatterns(patterns ...string) Redactor {
+	r.keyPatterns = r.keyPatterns[:len(r.keyPatterns):len(r.keyPatterns)]
+	for _, each := range patterns {
+		r.keyPatterns = append(r.keyPatterns, strings.ToLower(each))
+	}
+	return r
+}
+
+// RedactHeader returns a copy of the headers with sensitive values masked.
+func (r Redactor) RedactHeader(h http.Header) http.Header {
+	if h == nil {
+		return nil
+	}
+	c := h.Clone()
+	for _, each := range r.headers {
+		values := c[each]
+		for i := range values {
+			values[i] = r.mask
+		}
+	}
+	return c
+}
+
+// RedactPayload returns the payload with sensitive values masked, depending on its content type.
+// A payload that cannot be parsed, e.g. because it was truncated, is masked on a best effort basis.
+func (r Redactor) RedactPayload(contentType string, payload []byte) []byte {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mediaType == "application/x-www-form-urlencoded" && len(r.formFields) > 0:
+		return r.redactForm(payload)
+	case (mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")) && len(r.jsonPaths) > 0:
+		return r.redactJSON(payload)
+	}
+	return payload
+}
+
+func (r Redactor) redactForm(payload []byte) []byte {
+	values, err := url.ParseQuery(string(payload))
+	if err != nil {
+		return payload
+	}
+	changed := false
+	for _, each := range r.formFields {
+		if _, ok := values[each]; ok {
+			values.Set(each, r.mask)
+			changed = true
+		}
+	}
+	if !changed {
+		return payload
+	}
+	return []byte(values.Encode())
+}
+
+func (r Redactor) redactJSON(payload []byte) []byte {
+	dec := json.NewDecoder(bytes.NewReader(payload))
+	// keep large numbers as is
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil || dec.More() {
+		// mask string values of the last key of each path
+		for _, each := range r.jsonPaths {
+			if each.fallback != nil {
+				payload = each.fallback.ReplaceAll(payload, []byte(`${1}"`+r.mask+`${2}`))
+			}
+		}
+		return payload
+	}
+	changed := false
+	for _, each := range r.jsonPaths {
+		var masked bool
+		doc, masked = r.maskPath(doc, each.keys)
+		changed = changed || masked
+	}
+	if !changed {
+		return payload
+	}
+	buf := new(bytes.Buffer)
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(doc); err != nil {
+		return payload
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+}
+
+func (r Redactor) maskPath(doc any, keys []string) (any, bool) {
+	if len(keys) == 0 {
+		return r.mask, true
+	}
+	changed := false
+	switch v := doc.(type) {
+	case map[string]any:
+		for k, each := range v {
+			if keys[0] == "*" || keys[0] == k {
+				var masked bool
+				v[k], masked = r.maskPath(each, keys[1:])
+				changed = changed || masked
+			}
+		}
+	case []any:
+		if keys[0] == "*" {
+			for i, each := range v {
+				var masked bool
+				v[i], masked = r.maskPath(each, keys[1:])
+				changed = changed || masked
+			}
+		}
+	}
+	return doc, changed
+}
+
+// RedactAttr returns the attribute with its value masked if the key matches a pattern.
+// Attributes of a group are redacted recursively.
+func (r Redactor) RedactAttr(a slog.Attr) slog.Attr {
+	return r.redactAttr("", a)
+}
+
+func (r Redactor) redactAttr(prefix string, a slog.Attr) slog.Attr {
+	a.Value = a.Value.Resolve()
+	key := strings.ToLower(prefix + a.Key)
+	for _, each := range r.keyPatterns {
+		if ok, _ := path.Match(each, key); ok {
+			return slog.String(a.Key, r.mask)
+		}
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix = prefix + a.Key + "."
+		}
+		group := a.Value.Group()
+		redacted := make([]slog.Attr, len(group))
+		for i, each := range group {
+			redacted[i] = r.redactAttr(prefix, each)
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(redacted...)}
+	}
+	return a
+}
+
+// redactRecord returns a copy of the record with its attributes redacted.
+func (r Redactor) redactRecord(record slog.Record) slog.Record {
+	clone := slog.NewRecord(record.Time, record.Level, record.Message, record.PC)
+	record.Attrs(func(a slog.Attr) bool {
+		clone.AddAttrs(r.RedactAttr(a))
+		return true
+	})
+	return clone
+}
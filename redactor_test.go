@@ -0,0 +1,135 @@
+package recall
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRedactorHeader(t *testing.T) {
+	h := http.Header{}
+	h.Set("Authorization", "Bearer secret")
+	h.Set("Accept", "text/plain")
+	out := NewRedactor().RedactHeader(h)
+	if got, want := out.Get("Authorization"), "***"; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if got, want := out.Get("Accept"), "text/plain"; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if got, want := h.Get("Authorization"), "Bearer secret"; got != want {
+		t.Errorf("original changed, got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
+
+func TestRedactorJSONPayload(t *testing.T) {
+	r := NewRedactor().WithJSONPaths("$.users.*.token", "card.number")
+	in := `{"password":"p","users":[{"name":"a","token":"t1"},{"name":"b","token":"t2"}],"card":{"number":"1234"}}`
+	out := r.RedactPayload("application/json; charset=utf-8", []byte(in))
+	want := `{"card":{"number":"***"},"password":"***","users":[{"name":"a","token":"***"},{"name":"b","token":"***"}]}`
+	if got := string(out); got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
+
+func TestRedactorTruncatedJSONPayload(t *testing.T) {
+	in := `{"name":"a","password":"p\"q","other":"x","password":"trunc`
+	out := NewRedactor().RedactPayload("application/json", []byte(in))
+	want := `{"name":"a","password":"***","other":"x","password":"***`
+	if got := string(out); got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
+
+func TestRedactorFormPayload(t *testing.T) {
+	out := NewRedactor().RedactPayload("application/x-www-form-urlencoded", []byte("user=joe&password=secret"))
+	if got, want := string(out), "password=%2A%2A%2A&user=joe"; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	out = NewRedactor().RedactPayload("text/plain", []byte("password=secret"))
+	if got, want := string(out), "password=secret"; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
+
+func TestRedactorAttr(t *testing.T) {
+	r := NewRedactor()
+	if got, want := r.RedactAttr(slog.String("AccessToken", "t")).Value.String(), "***"; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if got, want := r.RedactAttr(slog.Int("count", 1)).Value.Int64(), int64(1); got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	g := r.RedactAttr(slog.Group("user", "name", "joe", "password", "p"))
+	if got, want := g.Value.Group()[1].Value.String(), "***"; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	// pattern on full key
+	r = Redactor{mask: "-"}.WithAttrKeyPatterns("user.name")
+	g = r.RedactAttr(slog.Group("user", "name", "joe"))
+	if got, want := g.Value.Group()[0].Value.String(), "-"; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
+
+func TestRecorderFlushRedacts(t *testing.T) {
+	rec := new(recording)
+	r := newRecorder(rec, "%s")
+	red := NewRedactor()
+	r.redactor = &red
+	slog.New(r).Debug("login", "password", "secret")
+	if got, want := attrsFrom(r.records[0])[0].Value.String(), "secret"; got != want {
+		t.Errorf("must not redact when recording, got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	r.flush(context.Background(), FailureInfo{}, true)
+	if got, want := attrsFrom(rec.records[0])[0].Value.String(), "***"; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
+
+func TestRecallHandlerRedactor(t *testing.T) {
+	rec := new(recording)
+	old := slog.Default()
+	slog.SetDefault(slog.New(rec))
+	defer slog.SetDefault(old)
+
+	h := NewRecallHandler(erroringHandler{}).WithRedactor(NewRedactor())
+	req, _ := http.NewRequest("POST", "/", bytes.NewBufferString(`{"password":"secret"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "secret")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	last := rec.records[len(rec.records)-1]
+	for _, each := range attrsFrom(last) {
+		switch each.Key {
+		case "headers":
+			if got, want := each.Value.Any().(http.Header).Get("Authorization"), "***"; got != want {
+				t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+			}
+		case "payload":
+			if got, want := each.Value.Any().(map[string]any)["password"], "***"; got != want {
+				t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+			}
+		}
+	}
+	// the recorded debug record has the payload as data attribute
+	if got, want := attrsFrom(rec.records[0])[0].Value.String(), `{"password":"secret"}`; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
+
+func TestRedactorJSONPayloadKeepsNumbersAndHTML(t *testing.T) {
+	r := NewRedactor()
+	got := string(r.RedactPayload("application/json", []byte(`{"id":12345678901234567890,"note":"<b>","password":"x"}`)))
+	if want := `{"id":12345678901234567890,"note":"<b>","password":"***"}`; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	// unchanged if nothing is masked
+	payload := `{"z":1.50,"a":2}`
+	if got := string(r.RedactPayload("application/json", []byte(payload))); got != payload {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, payload)
+	}
+}
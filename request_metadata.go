@@ -0,0 +1,71 @@
+package recall
+
+import (
+	"crypto/tls"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// requestMetadata returns the attributes that describe the request beyond its method, url, headers and payload.
+func requestMetadata(x exchange) []slog.Attr {
+	r := x.request
+	attrs := []slog.Attr{slog.String("remote_addr", r.RemoteAddr)}
+	if client := forwardedFor(r.Header); client != "" {
+		attrs = append(attrs, slog.String("client_addr", client))
+	}
+	if r.Pattern != "" {
+		attrs = append(attrs, slog.String("route", r.Pattern))
+		if values := pathValues(r); len(values) > 0 {
+			attrs = append(attrs, slog.Any("path_values", values))
+		}
+	}
+	attrs = append(attrs, slog.String("proto", r.Proto))
+	if r.TLS != nil {
+		attrs = append(attrs, slog.Group("tls",
+			slog.String("version", tls.VersionName(r.TLS.Version)),
+			slog.String("cipher_suite", tls.CipherSuiteName(r.TLS.CipherSuite)),
+			slog.String("server_name", r.TLS.ServerName),
+			slog.String("negotiated_protocol", r.TLS.NegotiatedProtocol)))
+	}
+	return append(attrs,
+		slog.Duration("duration", time.Since(x.start)),
+		slog.Int("bytes_read", x.body.bytesRead),
+		slog.Int("bytes_written", x.response.bytesWritten))
+}
+
+// forwardedFor returns the address of the originating client as set by proxies, if any.
+// The Forwarded header (RFC 7239) takes precedence over X-Forwarded-For.
+func forwardedFor(h http.Header) string {
+	if fwd := h.Get("Forwarded"); fwd != "" {
+		first, _, _ := strings.Cut(fwd, ",")
+		for _, pair := range strings.Split(first, ";") {
+			key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			if ok && strings.EqualFold(key, "for") {
+				return strings.Trim(value, `"`)
+			}
+		}
+	}
+	if xff := h.Get("X-Forwarded-For"); xff != "" {
+		first, _, _ := strings.Cut(xff, ",")
+		return strings.TrimSpace(first)
+	}
+	return ""
+}
+
+var wildcardPattern = regexp.MustCompile(`\{([^}.$]+)(?:\.\.\.)?\}`)
+
+// pathValues returns the values of the wildcards of the route pattern.
+func pathValues(r *http.Request) map[string]string {
+	matches := wildcardPattern.FindAllStringSubmatch(r.Pattern, -1)
+	if len(matches) == 0 {
+		return nil
+	}
+	values := make(map[string]string, len(matches))
+	for _, each := range matches {
+		values[each[1]] = r.PathValue(each[1])
+	}
+	return values
+}
@@ -0,0 +1,78 @@
+package recall
+
+import (
+	"bytes"
+	"crypto/tls"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestForwardedFor(t *testing.T) {
+	for _, each := range []struct {
+		header http.Header
+		want   string
+	}{
+		{http.Header{}, ""},
+		{http.Header{"X-Forwarded-For": {"203.0.113.1, 10.0.0.1"}}, "203.0.113.1"},
+		{http.Header{"Forwarded": {`for="[2001:db8::1]:4711";proto=https, for=10.0.0.1`}}, "[2001:db8::1]:4711"},
+		{http.Header{"Forwarded": {"proto=https;For=192.0.2.60"}, "X-Forwarded-For": {"10.0.0.1"}}, "192.0.2.60"},
+	} {
+		if got := forwardedFor(each.header); got != each.want {
+			t.Errorf("got [%v] want [%v]", got, each.want)
+		}
+	}
+}
+
+func TestRecallHandlerRequestMetadata(t *testing.T) {
+	rec := new(recording)
+	old := slog.Default()
+	slog.SetDefault(slog.New(rec))
+	defer slog.SetDefault(old)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /users/{id}/files/{path...}", func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("failed"))
+	})
+	h := NewRecallHandler(mux).WithRequestMetadata(true).WithRequestAttrs(func(r *http.Request) []slog.Attr {
+		return []slog.Attr{slog.String("user", "joe")}
+	})
+	req := httptest.NewRequest("POST", "/users/42/files/a/b.txt", bytes.NewBufferString("content"))
+	req.Header.Set("X-Forwarded-For", "203.0.113.1")
+	req.TLS = &tls.ConnectionState{Version: tls.VersionTLS13, ServerName: "example.com"}
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	attrs := map[string]slog.Value{}
+	for _, each := range attrsFrom(rec.records[0]) {
+		attrs[each.Key] = each.Value
+	}
+	if got, want := attrs["client_addr"].String(), "203.0.113.1"; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if got, want := attrs["route"].String(), "POST /users/{id}/files/{path...}"; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	values := attrs["path_values"].Any().(map[string]string)
+	if got, want := values["id"]+" "+values["path"], "42 a/b.txt"; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if got, want := attrs["tls"].Group()[0].Value.String(), "TLS 1.3"; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if got, want := attrs["bytes_read"].Int64(), int64(7); got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if got, want := attrs["bytes_written"].Int64(), int64(6); got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if got, want := attrs["user"].String(), "joe"; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if _, ok := attrs["duration"]; !ok {
+		t.Error("missing duration")
+	}
+}
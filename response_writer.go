@@ -0,0 +1,226 @@
+package recall
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"net/http"
+)
+
+// statusCodeRecorder captures the status code and (part of) the body written by a handler.
+// Use wrapped() to pass it to a handler such that the optional interfaces of the original writer remain visible.
+type statusCodeRecorder struct {
+	http.ResponseWriter
+	statusCode   int
+	wroteHeader  bool
+	limit        int
+	buffer       *bytes.Buffer
+	bytesWritten int
+}
+
+func (h *statusCodeRecorder) WriteHeader(c int) {
+	// informational headers can be followed by another WriteHeader
+	if !h.wroteHeader && (c < 100 || c > 199 || c == http.StatusSwitchingProtocols) {
+		h.statusCode = c
+		h.wroteHeader = true
+	}
+	h.ResponseWriter.WriteHeader(c)
+}
+
+// implicitOK records the status that the server sends when no WriteHeader was called.
+func (h *statusCodeRecorder) implicitOK() {
+	if !h.wroteHeader {
+		h.statusCode = http.StatusOK
+		h.wroteHeader = true
+	}
+}
+
+func (h *statusCodeRecorder) Write(p []byte) (n int, err error) {
+	h.implicitOK()
+	n, err = h.ResponseWriter.Write(p)
+	h.capture(p[:n])
+	return
+}
+
+func (h *statusCodeRecorder) capture(p []byte) {
+	// write to buffer until hit limit
+	if size := h.buffer.Len(); size < h.limit {
+		max := min(len(p), h.limit-size)
+		h.buffer.Write(p[:max])
+	}
+	h.bytesWritten += len(p)
+}
+
+func (h *statusCodeRecorder) recorded() string {
+	return recordedPayload(h.buffer.Bytes(), h.buffer.Len() < h.bytesWritten, h.limit, h.bytesWritten)
+}
+
+// Unwrap is used by http.ResponseController.
+func (h *statusCodeRecorder) Unwrap() http.ResponseWriter {
+	return h.ResponseWriter
+}
+
+func (h *statusCodeRecorder) Flush() {
+	h.implicitOK()
+	h.ResponseWriter.(http.Flusher).Flush()
+}
+
+func (h *statusCodeRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := h.ResponseWriter.(http.Hijacker).Hijack()
+	if err == nil {
+		// the handler owns the connection ; nothing can be written anymore
+		h.wroteHeader = true
+	}
+	return conn, rw, err
+}
+
+func (h *statusCodeRecorder) Push(target string, opts *http.PushOptions) error {
+	return h.ResponseWriter.(http.Pusher).Push(target, opts)
+}
+
+func (h *statusCodeRecorder) ReadFrom(src io.Reader) (n int64, err error) {
+	h.implicitOK()
+	if h.limit > h.buffer.Len() {
+		// copy through Write to capture the body ; hide ReadFrom to prevent recursion
+		return io.Copy(struct{ io.Writer }{h}, src)
+	}
+	n, err = h.ResponseWriter.(io.ReaderFrom).ReadFrom(src)
+	h.bytesWritten += int(n)
+	return
+}
+
+type rwUnwrapper interface {
+	Unwrap() http.ResponseWriter
+}
+
+// wrapped returns a writer that exposes exactly the optional interfaces implemented by the original writer.
+func (h *statusCodeRecorder) wrapped() http.ResponseWriter {
+	mask := 0
+	if _, ok := h.ResponseWriter.(http.Flusher); ok {
+		mask |= 1
+	}
+	if _, ok := h.ResponseWriter.(http.Hijacker); ok {
+		mask |= 2
+	}
+	if _, ok := h.ResponseWriter.(io.ReaderFrom); ok {
+		mask |= 4
+	}
+	if _, ok := h.ResponseWriter.(http.Pusher); ok {
+		mask |= 8
+	}
+	switch mask {
+	case 15:
+		return struct {
+			http.ResponseWriter
+			rwUnwrapper
+			http.Flusher
+			http.Hijacker
+			io.ReaderFrom
+			http.Pusher
+		}{h, h, h, h, h, h}
+	case 14:
+		return struct {
+			http.ResponseWriter
+			rwUnwrapper
+			http.Hijacker
+			io.ReaderFrom
+			http.Pusher
+		}{h, h, h, h, h}
+	case 13:
+		return struct {
+			http.ResponseWriter
+			rwUnwrapper
+			http.Flusher
+			io.ReaderFrom
+			http.Pusher
+		}{h, h, h, h, h}
+	case 12:
+		return struct {
+			http.ResponseWriter
+			rwUnwrapper
+			io.ReaderFrom
+			http.Pusher
+		}{h, h, h, h}
+	case 11:
+		return struct {
+			http.ResponseWriter
+			rwUnwrapper
+			http.Flusher
+			http.Hijacker
+			http.Pusher
+		}{h, h, h, h, h}
+	case 10:
+		return struct {
+			http.ResponseWriter
+			rwUnwrapper
+			http.Hijacker
+			http.Pusher
+		}{h, h, h, h}
+	case 9:
+		return struct {
+			http.ResponseWriter
+			rwUnwrapper
+			http.Flusher
+			http.Pusher
+		}{h, h, h, h}
+	case 8:
+		return struct {
+			http.ResponseWriter
+			rwUnwrapper
+			http.Pusher
+		}{h, h, h}
+	case 7:
+		return struct {
+			http.ResponseWriter
+			rwUnwrapper
+			http.Flusher
+			http.Hijacker
+			io.ReaderFrom
+		}{h, h, h, h, h}
+	case 6:
+		return struct {
+			http.ResponseWriter
+			rwUnwrapper
+			http.Hijacker
+			io.ReaderFrom
+		}{h, h, h, h}
+	case 5:
+		return struct {
+			http.ResponseWriter
+			rwUnwrapper
+			http.Flusher
+			io.ReaderFrom
+		}{h, h, h, h}
+	case 4:
+		return struct {
+			http.ResponseWriter
+			rwUnwrapper
+			io.ReaderFrom
+		}{h, h, h}
+	case 3:
+		return struct {
+			http.ResponseWriter
+			rwUnwrapper
+			http.Flusher
+			http.Hijacker
+		}{h, h, h, h}
+	case 2:
+		return struct {
+			http.ResponseWriter
+			rwUnwrapper
+			http.Hijacker
+		}{h, h, h}
+	case 1:
+		return struct {
+			http.ResponseWriter
+			rwUnwrapper
+			http.Flusher
+		}{h, h, h}
+	default:
+		return struct {
+			http.ResponseWriter
+			rwUnwrapper
+		}{h, h}
+	}
+}
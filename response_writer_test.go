@@ -0,0 +1,118 @@
+package recall
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestStatusCodeRecorderImplicitOK(t *testing.T) {
+	rec := &statusCodeRecorder{ResponseWriter: httptest.NewRecorder(), buffer: new(bytes.Buffer)}
+	rec.wrapped().Write([]byte("hello"))
+	if got, want := rec.statusCode, http.StatusOK; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	rec.WriteHeader(http.StatusTeapot)
+	if got, want := rec.statusCode, http.StatusOK; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
+
+func TestStatusCodeRecorderInformational(t *testing.T) {
+	rec := &statusCodeRecorder{ResponseWriter: httptest.NewRecorder(), buffer: new(bytes.Buffer)}
+	rec.WriteHeader(http.StatusEarlyHints)
+	rec.WriteHeader(http.StatusBadGateway)
+	if got, want := rec.statusCode, http.StatusBadGateway; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
+
+func TestStatusCodeRecorderOptionalInterfaces(t *testing.T) {
+	rec := &statusCodeRecorder{ResponseWriter: httptest.NewRecorder(), buffer: new(bytes.Buffer)}
+	w := rec.wrapped()
+	if _, ok := w.(http.Flusher); !ok {
+		t.Error("expected Flusher")
+	}
+	if _, ok := w.(http.Hijacker); ok {
+		t.Error("unexpected Hijacker")
+	}
+	if _, ok := w.(http.Pusher); ok {
+		t.Error("unexpected Pusher")
+	}
+	if err := http.NewResponseController(w).Flush(); err != nil {
+		t.Error(err)
+	}
+	if got, want := rec.statusCode, http.StatusOK; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	// reach original through Unwrap
+	if err := http.NewResponseController(w).EnableFullDuplex(); err == nil {
+		t.Error("expected not supported error")
+	}
+}
+
+type readerFromWriter struct {
+	*httptest.ResponseRecorder
+	readFromCalled bool
+}
+
+func (r *readerFromWriter) ReadFrom(src io.Reader) (int64, error) {
+	r.readFromCalled = true
+	return io.Copy(r.ResponseRecorder, src)
+}
+
+func TestStatusCodeRecorderReadFrom(t *testing.T) {
+	rw := &readerFromWriter{ResponseRecorder: httptest.NewRecorder()}
+	rec := &statusCodeRecorder{ResponseWriter: rw, limit: 4, buffer: new(bytes.Buffer)}
+	n, _ := rec.wrapped().(io.ReaderFrom).ReadFrom(strings.NewReader("response"))
+	if got, want := n, int64(8); got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if got, want := rec.recorded(), "resp..(4 of 8)"; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if rw.readFromCalled {
+		t.Error("expected copy through Write when capturing")
+	}
+	// without capture the original ReadFrom is used
+	rec = &statusCodeRecorder{ResponseWriter: rw, buffer: new(bytes.Buffer)}
+	rec.wrapped().(io.ReaderFrom).ReadFrom(strings.NewReader("response"))
+	if !rw.readFromCalled {
+		t.Error("expected ReadFrom of original")
+	}
+	if got, want := rec.bytesWritten, 8; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
+
+type hijackableWriter struct {
+	*httptest.ResponseRecorder
+	hijacked bool
+}
+
+func (w *hijackableWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.hijacked = true
+	return nil, nil, nil
+}
+
+func TestRecallHandlerPanicAfterHijack(t *testing.T) {
+	w := &hijackableWriter{ResponseRecorder: httptest.NewRecorder()}
+	h := NewRecallHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, _, err := http.NewResponseController(w).Hijack(); err != nil {
+			t.Fatal(err)
+		}
+		panic("after hijack")
+	}))
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if !w.hijacked {
+		t.Fatal("expected hijack")
+	}
+	if got, want := w.Code, http.StatusOK; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
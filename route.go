@@ -0,0 +1,78 @@
+package recall
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// routeConfig holds the configuration function for a ServeMux pattern or a path prefix.
+type routeConfig struct {
+	pattern   string
+	configure func(RecallHandler) RecallHandler
+}
+
+// isPrefix returns true if the pattern can be used to match the start of a request path.
+func (c routeConfig) isPrefix() bool {
+	return strings.HasPrefix(c.pattern, "/") && strings.HasSuffix(c.pattern, "/") && !strings.Contains(c.pattern, "{")
+}
+
+// WithRoute sets the configuration for requests that are handled by a ServeMux pattern or that match a path prefix.
+// A pattern (e.g. "POST /upload/{id}") matches if it equals the pattern of the route that handles the request.
+// A path prefix (e.g. "/payments/") matches if the request path starts with it ; the longest prefix wins.
+// The settings are needed before the request is served, so a pattern can only match if the next handler is a *http.ServeMux
+// (or the request already has a Pattern) ; if the mux is wrapped by another handler then use path prefixes instead.
+// The configure function receives a copy of this handler and returns the handler to use for that route, e.g.
+//
+//	h.WithRoute("/payments/", func(rh recall.RecallHandler) recall.RecallHandler {
+//		return rh.WithFailurePolicy(recall.AllErrors)
+//	})
+func (h RecallHandler) WithRoute(patternOrPrefix string, configure func(RecallHandler) RecallHandler) RecallHandler {
+	route := routeConfig{pattern: patternOrPrefix, configure: configure}
+	if _, ok := h.next.(*http.ServeMux); !ok && !route.isPrefix() {
+		slog.Warn(fmt.Sprintf(h.messageFormat, "route pattern cannot be matched because the next handler is not a *http.ServeMux"), "pattern", patternOrPrefix)
+	}
+	h.routes = append(h.routes[:len(h.routes):len(h.routes)], route)
+	return h
+}
+
+// WithEnabled enables or disables the recording of logs and the logging of failures. Default is true.
+// A disabled handler only calls the next handler ; use it with WithRoute to exclude routes.
+func (h RecallHandler) WithEnabled(enabled bool) RecallHandler {
+	h.enabled = enabled
+	return h
+}
+
+// forRoute returns the handler configured for the route, with the pattern, that handles the request.
+func (h RecallHandler) forRoute(r *http.Request, pattern string) RecallHandler {
+	routes := h.routes
+	h.routes = nil
+	var prefix *routeConfig
+	for i, each := range routes {
+		if pattern != "" && each.pattern == pattern {
+			return each.configure(h)
+		}
+		if each.isPrefix() && strings.HasPrefix(r.URL.Path, each.pattern) {
+			if prefix == nil || len(each.pattern) > len(prefix.pattern) {
+				prefix = &routes[i]
+			}
+		}
+	}
+	if prefix != nil {
+		return prefix.configure(h)
+	}
+	return h
+}
+
+// routePattern returns the pattern of the route that handles the request, if known before serving it.
+func (h RecallHandler) routePattern(r *http.Request) string {
+	if r.Pattern != "" {
+		return r.Pattern
+	}
+	if mux, ok := h.next.(*http.ServeMux); ok {
+		_, pattern := mux.Handler(r)
+		return pattern
+	}
+	return ""
+}
@@ -0,0 +1,91 @@
+package recall
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRecallHandlerWithRoute(t *testing.T) {
+	rec := new(recording)
+	old := slog.Default()
+	slog.SetDefault(slog.New(rec))
+	defer slog.SetDefault(old)
+
+	mux := http.NewServeMux()
+	status := func(code int) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(code) }
+	}
+	mux.Handle("POST /upload/{id}", status(500))
+	mux.Handle("/payments/", status(400))
+	mux.Handle("/payments/refunds/", status(400))
+	mux.Handle("/other", status(500))
+
+	h := NewRecallHandler(mux).
+		WithRoute("POST /upload/{id}", func(rh RecallHandler) RecallHandler {
+			return rh.WithEnabled(false)
+		}).
+		WithRoute("/payments/", func(rh RecallHandler) RecallHandler {
+			return rh.WithFailurePolicy(AllErrors)
+		}).
+		WithRoute("/payments/refunds/", func(rh RecallHandler) RecallHandler {
+			return rh.WithMessageFormat("refunds %s")
+		})
+
+	for _, each := range []struct {
+		method, path string
+		logged       int
+	}{
+		{"POST", "/upload/1", 0},
+		{"GET", "/payments/1", 1},
+		{"GET", "/payments/refunds/1", 0}, // longest prefix, default policy
+		{"GET", "/other", 1},
+	} {
+		rec.records = nil
+		req, _ := http.NewRequest(each.method, each.path, nil)
+		h.ServeHTTP(httptest.NewRecorder(), req)
+		if got, want := len(rec.records), each.logged; got != want {
+			t.Errorf("%s %s: got [%v] want [%v]", each.method, each.path, got, want)
+		}
+	}
+}
+
+func TestRouteConfigIsPrefix(t *testing.T) {
+	for pattern, want := range map[string]bool{
+		"/payments/":       true,
+		"/upload/{id}":     false,
+		"/upload/{id}/":    false,
+		"GET /payments/":   false,
+		"/exact":           false,
+		"example.com/a/":   false,
+		"/":                true,
+		"/files/{path...}": false,
+	} {
+		if got := (routeConfig{pattern: pattern}).isPrefix(); got != want {
+			t.Errorf("%s: got [%v] want [%v]", pattern, got, want)
+		}
+	}
+}
+
+func TestRecallHandlerWithRouteWarnsForWrappedMux(t *testing.T) {
+	rec := new(recording)
+	old := slog.Default()
+	slog.SetDefault(slog.New(rec))
+	defer slog.SetDefault(old)
+
+	keep := func(h RecallHandler) RecallHandler { return h }
+	wrapped := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	NewRecallHandler(wrapped).WithRoute("/payments/", keep)
+	if got, want := len(rec.records), 0; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	NewRecallHandler(wrapped).WithRoute("POST /upload/{id}", keep)
+	if got, want := len(rec.records), 1; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	NewRecallHandler(http.NewServeMux()).WithRoute("POST /upload/{id}", keep)
+	if got, want := len(rec.records), 1; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
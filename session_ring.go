@@ -0,0 +1,156 @@
+package recall
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RecentSession is a failure with its recorded log records as kept by a SessionRing.
+type RecentSession struct {
+	ID      int               `json:"id"`
+	Time    time.Time         `json:"time"`
+	Route   string            `json:"route,omitempty"`
+	Method  string            `json:"method,omitempty"`
+	Path    string            `json:"path,omitempty"`
+	Status  int               `json:"status,omitempty"`
+	Error   string            `json:"error,omitempty"`
+	Failure map[string]any    `json:"failure"`
+	Records []json.RawMessage `json:"records"`
+}
+
+// SessionRing is a Sink that keeps the last flushed failures in memory.
+// It is also a http.Handler that serves these as JSON or, with query parameter format=html, as a HTML page.
+// The query parameters route (pattern or path prefix), status (e.g. 500 or 5xx) and since (e.g. 5m or a RFC3339 time) filter the failures.
+type SessionRing struct {
+	mu       sync.Mutex
+	sessions []RecentSession
+	next     int // index of the next session to write
+	count    int
+	sequence int
+}
+
+// NewSessionRing returns a SessionRing that keeps the last size failures.
+func NewSessionRing(size int) *SessionRing {
+	return &SessionRing{sessions: make([]RecentSession, max(size, 1))}
+}
+
+// Flush implements Sink
+func (s *SessionRing) Flush(ctx context.Context, info FailureInfo, records []slog.Record) error {
+	doc, err := newSessionDocument(info, records)
+	if err != nil {
+		return err
+	}
+	session := RecentSession{Time: doc.Time, Status: info.StatusCode, Failure: doc.Failure, Records: doc.Records}
+	if info.Request != nil {
+		session.Route = info.Request.Pattern
+		session.Method = info.Request.Method
+		session.Path = info.Request.URL.Path
+	}
+	if info.Err != nil {
+		session.Error = info.Err.Error()
+	}
+	if info.Recovered != nil {
+		session.Error = fmt.Sprint("panic: ", info.Recovered)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sequence++
+	session.ID = s.sequence
+	s.sessions[s.next] = session
+	s.next = (s.next + 1) % len(s.sessions)
+	s.count = min(s.count+1, len(s.sessions))
+	return nil
+}
+
+// Sessions returns the kept failures, most recent first.
+func (s *SessionRing) Sessions() []RecentSession {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]RecentSession, 0, s.count)
+	for i := 1; i <= s.count; i++ {
+		list = append(list, s.sessions[(s.next-i+len(s.sessions))%len(s.sessions)])
+	}
+	return list
+}
+
+// sessionFilter selects sessions by query parameters.
+type sessionFilter struct {
+	route  string
+	status string
+	since  time.Time
+}
+
+func newSessionFilter(r *http.Request) (f sessionFilter, err error) {
+	q := r.URL.Query()
+	f.route = q.Get("route")
+	f.status = q.Get("status")
+	if since := q.Get("since"); since != "" {
+		if d, derr := time.ParseDuration(since); derr == nil {
+			f.since = time.Now().Add(-d)
+		} else if f.since, err = time.Parse(time.RFC3339, since); err != nil {
+			return f, err
+		}
+	}
+	return f, nil
+}
+
+func (f sessionFilter) matches(s RecentSession) bool {
+	if f.route != "" && s.Route != f.route && !strings.HasPrefix(s.Path, f.route) {
+		return false
+	}
+	if f.status != "" {
+		code := strconv.Itoa(s.Status)
+		if class, ok := strings.CutSuffix(f.status, "xx"); ok {
+			if !strings.HasPrefix(code, class) {
+				return false
+			}
+		} else if code != f.status {
+			return false
+		}
+	}
+	return f.since.IsZero() || !s.Time.Before(f.since)
+}
+
+// ServeHTTP implements http.Handler
+func (s *SessionRing) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	filter, err := newSessionFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	list := []RecentSession{}
+	for _, each := range s.Sessions() {
+		if filter.matches(each) {
+			list = append(list, each)
+		}
+	}
+	if r.URL.Query().Get("format") == "html" {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_ = sessionsPage.Execute(w, list)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(list)
+}
+
+var sessionsPage = template.Must(template.New("sessions").Parse(`<!DOCTYPE html>
+<html><head><title>Recent recalls</title>
+<style>body{font-family:sans-serif}table{border-collapse:collapse}td,th{border:1px solid #ccc;padding:4px;vertical-align:top;text-align:left}pre{margin:0}</style>
+</head><body>
+<h1>Recent recalls</h1>
+<table>
+<tr><th>#</th><th>time</th><th>request</th><th>route</th><th>status</th><th>error</th><th>records</th></tr>
+{{range .}}<tr><td>{{.ID}}</td><td>{{.Time.Format "2006-01-02 15:04:05.000"}}</td><td>{{.Method}} {{.Path}}</td><td>{{.Route}}</td><td>{{if .Status}}{{.Status}}{{end}}</td><td>{{.Error}}</td>
+<td><pre>{{range .Records}}{{printf "%s" .}}
+{{end}}</pre></td></tr>
+{{end}}</table>
+</body></html>
+`))
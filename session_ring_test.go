@@ -0,0 +1,88 @@
+package recall
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSessionRingKeepsLast(t *testing.T) {
+	ring := NewSessionRing(2)
+	for _, each := range []string{"a", "b", "c"} {
+		ring.Flush(context.Background(), FailureInfo{Time: time.Now(), Err: errors.New(each)}, nil)
+	}
+	list := ring.Sessions()
+	if got, want := len(list), 2; got != want {
+		t.Fatalf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if got, want := list[0].Error, "c"; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if got, want := list[1].ID, 2; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
+
+func TestSessionRingHandler(t *testing.T) {
+	ring := NewSessionRing(10)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /orders/{id}", func(w http.ResponseWriter, r *http.Request) {
+		Slog(r.Context()).Debug("order <lookup>")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	mux.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		Slog(r.Context()).Debug("user")
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	h := NewRecallHandler(mux).WithSink(ring).WithFailurePolicy(AllErrors)
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/orders/1", nil))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/users/1", nil))
+
+	for query, want := range map[string]int{
+		"":                                       2,
+		"?route=GET+/orders/{id}":                1,
+		"?route=/users/":                         1,
+		"?status=5xx":                            2,
+		"?status=500":                            1,
+		"?since=1m":                              2,
+		"?since=2000-01-01T00:00:00Z&status=404": 0,
+	} {
+		resp := httptest.NewRecorder()
+		ring.ServeHTTP(resp, httptest.NewRequest("GET", "/"+query, nil))
+		var list []RecentSession
+		if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+			t.Fatal(err)
+		}
+		if got := len(list); got != want {
+			t.Errorf("%s: got [%v] want [%v]", query, got, want)
+		}
+	}
+
+	resp := httptest.NewRecorder()
+	ring.ServeHTTP(resp, httptest.NewRequest("GET", "/?format=html", nil))
+	if got, want := resp.Header().Get("Content-Type"), "text/html; charset=utf-8"; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if !strings.Contains(resp.Body.String(), "&lt;lookup&gt;") {
+		t.Error("expected escaped record message", resp.Body.String())
+	}
+
+	resp = httptest.NewRecorder()
+	ring.ServeHTTP(resp, httptest.NewRequest("GET", "/?since=yesterday", nil))
+	if got, want := resp.Code, http.StatusBadRequest; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
+
+func TestSessionRingPanic(t *testing.T) {
+	ring := NewSessionRing(1)
+	New(context.Background()).WithCaptureStrategy(RecordingStrategy).WithSink(ring).Call(willPanic)
+	if got, want := ring.Sessions()[0].Error, "panic: "; !strings.HasPrefix(got, want) {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
@@ -0,0 +1,226 @@
+package recall
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// FailureInfo describes the failure for which the recorded log records are flushed.
+type FailureInfo struct {
+	// Time is when the failure was detected.
+	Time time.Time
+	// Err is the error returned by the function called by a Recaller, if any.
+	Err error
+	// Recovered is the value recovered from a panic, if any.
+	Recovered any
+	// Request is the request handled by a RecallHandler, if any.
+	Request *http.Request
+	// StatusCode is the status of the response sent by a RecallHandler, if any.
+	StatusCode int
+	// Attrs describe the failure, such as the request details logged by a RecallHandler.
+	Attrs []slog.Attr
+}
+
+// attrs returns the attributes of the failure, except Attrs.
+func (f FailureInfo) attrs() (list []slog.Attr) {
+	if f.Err != nil {
+		list = append(list, slog.String("err", f.Err.Error()))
+	}
+	if f.Recovered != nil {
+		list = append(list, slog.String("recovered", fmt.Sprint(f.Recovered)))
+	}
+	if f.Request != nil {
+		list = append(list, slog.String("method", f.Request.Method), slog.String("url", f.Request.URL.String()))
+		if f.Request.Pattern != "" {
+			list = append(list, slog.String("route", f.Request.Pattern))
+		}
+	}
+	if f.StatusCode != 0 {
+		list = append(list, slog.Int("status", f.StatusCode))
+	}
+	return
+}
+
+// Sink receives the recorded log records of a failure.
+type Sink interface {
+	// Flush writes the records ; it must not retain the records slice.
+	Flush(ctx context.Context, info FailureInfo, records []slog.Record) error
+}
+
+// SinkFunc is an adapter to use a function as a Sink.
+type SinkFunc func(ctx context.Context, info FailureInfo, records []slog.Record) error
+
+// Flush implements Sink
+func (f SinkFunc) Flush(ctx context.Context, info FailureInfo, records []slog.Record) error {
+	return f(ctx, info, records)
+}
+
+// HandlerSink writes records to a slog.Handler. This is the default Sink, using the handler of the default logger.
+// Debug records are written at Info level with their message formatted, otherwise they would be filtered out.
+// A record that cannot be handled is written to a fallback handler instead and counted as MetricRecordsFailed.
+type HandlerSink struct {
+	handler       slog.Handler
+	messageFormat string
+	fallback      slog.Handler
+	onFlushError  func(err error, record slog.Record)
+}
+
+// NewHandlerSink returns a HandlerSink that writes to a handler using a message format with a single %s placeholder.
+// If the handler is nil then the handler of the default logger at the time of flushing is used.
+func NewHandlerSink(handler slog.Handler, messageFormat string) HandlerSink {
+	return HandlerSink{handler: handler, messageFormat: messageFormat}
+}
+
+// WithFallback sets the handler for records that could not be handled.
+// Default is a JSON handler writing to os.Stderr.
+func (s HandlerSink) WithFallback(h slog.Handler) HandlerSink {
+	s.fallback = h
+	return s
+}
+
+// WithOnFlushError sets the function that is called for each record that could not be handled, before it is written to the fallback handler.
+func (s HandlerSink) WithOnFlushError(f func(err error, record slog.Record)) HandlerSink {
+	s.onFlushError = f
+	return s
+}
+
+// stderrFallback writes records that could not be flushed as JSON to stderr.
+var stderrFallback slog.Handler = slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})
+
+// sinkMetricsKey is the context key for the Metrics of the Recaller or RecallHandler that flushes to a Sink.
+var sinkMetricsKey struct{ metrics bool }
+
+// sinkMetrics holds the Metrics, with its labels, of a flush.
+type sinkMetrics struct {
+	metrics Metrics
+	labels  MetricLabels
+}
+
+// contextWithSinkMetrics returns a context for flushing to a Sink that counts using the metrics.
+func contextWithSinkMetrics(ctx context.Context, metrics Metrics, labels MetricLabels) context.Context {
+	if metrics == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, sinkMetricsKey, sinkMetrics{metrics: metrics, labels: labels})
+}
+
+// countSink adds to the metric of the Recaller or RecallHandler that flushes, if any.
+func countSink(ctx context.Context, m Metric, delta int64) {
+	if sm, ok := ctx.Value(sinkMetricsKey).(sinkMetrics); ok {
+		count(sm.metrics, m, delta, sm.labels)
+	}
+}
+
+// Flush implements Sink
+func (s HandlerSink) Flush(ctx context.Context, info FailureInfo, records []slog.Record) error {
+	handler := s.handler
+	if handler == nil {
+		handler = slog.Default().Handler()
+	}
+	for _, record := range records {
+		if record.Level == slog.LevelDebug {
+			record.Message = fmt.Sprintf(s.messageFormat, record.Message)
+			// change level otherwise it will be filtered out
+			record.Level = slog.LevelInfo
+		}
+		if err := handler.Handle(ctx, record); err != nil {
+			countSink(ctx, MetricRecordsFailed, 1)
+			if s.onFlushError != nil {
+				s.onFlushError(err, record)
+			}
+			s.handleFallback(ctx, record)
+		}
+	}
+	return nil
+}
+
+// handleFallback writes the record to the fallback handler ; as a last resort it is written to stderr.
+func (s HandlerSink) handleFallback(ctx context.Context, record slog.Record) {
+	fallback := s.fallback
+	if fallback == nil {
+		fallback = stderrFallback
+	}
+	if err := fallback.Handle(ctx, record); err != nil && fallback != stderrFallback {
+		_ = stderrFallback.Handle(ctx, record)
+	}
+}
+
+// JSONLinesSink writes each record as a JSON object on a single line, including a "failure" group.
+type JSONLinesSink struct {
+	handler slog.Handler
+}
+
+// NewJSONLinesSink returns a JSONLinesSink that writes to w. Records are written with their original level.
+func NewJSONLinesSink(w io.Writer) JSONLinesSink {
+	return JSONLinesSink{handler: slog.NewJSONHandler(w, &slog.HandlerOptions{Level: slog.LevelDebug})}
+}
+
+// Flush implements Sink
+func (s JSONLinesSink) Flush(ctx context.Context, info FailureInfo, records []slog.Record) error {
+	failure := slog.Attr{Key: "failure", Value: slog.GroupValue(info.attrs()...)}
+	var errs []error
+	for _, each := range records {
+		line := each.Clone()
+		line.AddAttrs(failure)
+		errs = append(errs, s.handler.Handle(ctx, line))
+	}
+	return errors.Join(errs...)
+}
+
+// MemorySession holds the records of a failure collected by a MemorySink.
+type MemorySession struct {
+	Info    FailureInfo
+	Records []slog.Record
+}
+
+// MemorySink collects the records of failures in memory, e.g. for testing.
+type MemorySink struct {
+	mu       sync.Mutex
+	sessions []MemorySession
+}
+
+// Flush implements Sink
+func (m *MemorySink) Flush(ctx context.Context, info FailureInfo, records []slog.Record) error {
+	clones := make([]slog.Record, len(records))
+	for i, each := range records {
+		clones[i] = each.Clone()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessions = append(m.sessions, MemorySession{Info: info, Records: clones})
+	return nil
+}
+
+// Sessions returns the collected failures.
+func (m *MemorySink) Sessions() []MemorySession {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.sessions)
+}
+
+// Reset removes all collected failures.
+func (m *MemorySink) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessions = nil
+}
+
+// FanOut returns a Sink that flushes to all sinks, in order.
+// It returns the joined errors of all sinks.
+func FanOut(sinks ...Sink) Sink {
+	return SinkFunc(func(ctx context.Context, info FailureInfo, records []slog.Record) error {
+		var errs []error
+		for _, each := range sinks {
+			errs = append(errs, each.Flush(ctx, info, records))
+		}
+		return errors.Join(errs...)
+	})
+}
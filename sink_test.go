@@ -0,0 +1,165 @@
+package recall
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRecallRecordingWithMemorySink(t *testing.T) {
+	sink := new(MemorySink)
+	r := New(context.Background()).WithCaptureStrategy(RecordingStrategy).WithSink(sink)
+	r.Call(willError)
+	r.Call(noError)
+	sessions := sink.Sessions()
+	if got, want := len(sessions), 1; got != want {
+		t.Fatalf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if got, want := sessions[0].Info.Err.Error(), "error"; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if got, want := sessions[0].Records[0].Message, "will error"; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if sessions[0].Info.Time.IsZero() {
+		t.Error("expected time")
+	}
+	sink.Reset()
+	if got, want := len(sink.Sessions()), 0; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
+
+func TestRecallHandlerWithMemorySink(t *testing.T) {
+	sink := new(MemorySink)
+	h := NewRecallHandler(erroringHandler{}).WithSink(sink)
+	req, _ := http.NewRequest("GET", "/fail", bytes.NewBufferString("test"))
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	info := sink.Sessions()[0].Info
+	if got, want := info.StatusCode, 500; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if got, want := info.Request.URL.Path, "/fail"; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if got, want := info.Attrs[0].Key, "method"; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
+
+func TestJSONLinesSink(t *testing.T) {
+	buf := new(bytes.Buffer)
+	sink := NewJSONLinesSink(buf)
+	r := New(context.Background()).WithCaptureStrategy(RecordingStrategy).WithSink(sink)
+	r.Call(func(ctx context.Context) error {
+		Slog(ctx).Debug("one", "a", 1)
+		Slog(ctx).Debug("two")
+		return errors.New("failed")
+	})
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	if got, want := len(lines), 2; got != want {
+		t.Fatalf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	var line struct {
+		Level   string
+		Msg     string
+		A       int
+		Failure struct{ Err string }
+	}
+	if err := json.Unmarshal(lines[0], &line); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := line.Level+" "+line.Msg+" "+line.Failure.Err, "DEBUG one failed"; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if got, want := line.A, 1; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
+
+func TestFanOut(t *testing.T) {
+	first, second := new(MemorySink), new(MemorySink)
+	failing := SinkFunc(func(ctx context.Context, info FailureInfo, records []slog.Record) error {
+		return errors.New("unavailable")
+	})
+	err := FanOut(first, failing, second).Flush(context.Background(), FailureInfo{}, []slog.Record{{Message: "m"}})
+	if err == nil || err.Error() != "unavailable" {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if got, want := len(first.Sessions())+len(second.Sessions()), 2; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
+
+func TestHandlerSinkFallback(t *testing.T) {
+	fallback := new(recording)
+	fallback.level = slog.LevelDebug
+	var failed []string
+	sink := NewHandlerSink(badHandler{}, "[R] %s").WithFallback(fallback).WithOnFlushError(func(err error, record slog.Record) {
+		failed = append(failed, err.Error()+":"+record.Message)
+	})
+	record := slog.NewRecord(time.Now(), slog.LevelDebug, "lost", 0)
+	record.AddAttrs(slog.Group("g", "k", "v"))
+	sink.Flush(context.Background(), FailureInfo{}, []slog.Record{record})
+
+	if got, want := fmt.Sprint(failed), "[bad:[R] lost]"; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if got, want := len(fallback.records), 1; got != want {
+		t.Fatalf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if got, want := attrsFrom(fallback.records[0])[0].Value.Kind(), slog.KindGroup; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
+
+func TestHandlerSinkFailedMetric(t *testing.T) {
+	m := new(countingMetrics)
+	sink := NewHandlerSink(badHandler{}, "[R] %s").WithFallback(new(recording))
+	r := New(context.Background()).WithCaptureStrategy(RecordingStrategy).WithSink(sink).WithMetrics(m)
+	r.Call(willError)
+	if got, want := m.get("records_failed recording "), int64(1); got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
+
+func TestHandlerSinkDefaultHandler(t *testing.T) {
+	rec := new(recording)
+	old := slog.Default()
+	slog.SetDefault(slog.New(rec))
+	defer slog.SetDefault(old)
+	NewHandlerSink(nil, "%s").Flush(context.Background(), FailureInfo{}, []slog.Record{slog.NewRecord(time.Now(), slog.LevelDebug, "m", 0)})
+	if got, want := len(rec.records), 1; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
+
+func TestRecallRecordingSinkThatLogs(t *testing.T) {
+	old := slog.Default()
+	defer slog.SetDefault(old)
+	slog.SetDefault(slog.New(new(recording)))
+	sink := SinkFunc(func(ctx context.Context, info FailureInfo, records []slog.Record) error {
+		Slog(ctx).Error("sink")
+		Slog(ctx).Debug("sink")
+		return nil
+	})
+	done := make(chan error)
+	go func() {
+		done <- New(context.Background()).WithCaptureStrategy(RecordingStrategy).WithSink(sink).Call(willError)
+	}()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("expected error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("flush did not complete")
+	}
+}
@@ -0,0 +1,84 @@
+package recall
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// TaskKey is the attribute key of the task number of log records from goroutines started by Go or GroupGo.
+const TaskKey = "recall.task"
+
+// Go calls f in a new goroutine. If the logger of ctx is recording then a recall waits for the goroutine
+// to finish (see WithTaskWait) before writing the records, and records logged by f have a task number attribute.
+func Go(ctx context.Context, f func(ctx context.Context)) {
+	ctx, done := startTask(ctx)
+	go func() {
+		defer done()
+		f(ctx)
+	}()
+}
+
+// GroupGo calls f using the Go method of g, e.g. an errgroup.Group, and is tracked like Go.
+func GroupGo(g interface{ Go(func() error) }, ctx context.Context, f func(ctx context.Context) error) {
+	ctx, done := startTask(ctx)
+	g.Go(func() error {
+		defer done()
+		return f(ctx)
+	})
+}
+
+// startTask registers a task with the recorder of the context logger, if any, and returns the context for the task.
+func startTask(ctx context.Context) (context.Context, func()) {
+	log := Slog(ctx)
+	rec := recorderOf(log.Handler())
+	if rec == nil {
+		return ctx, func() {}
+	}
+	rec.mux.Lock()
+	rec.taskCount++
+	task := rec.taskCount
+	if rec.running == 0 {
+		rec.idle = make(chan struct{})
+	}
+	rec.running++
+	rec.mux.Unlock()
+	return ContextWithLogger(ctx, log.With(TaskKey, task)), rec.taskDone
+}
+
+// taskDone is called when a goroutine started by Go or GroupGo has finished.
+func (r *recorder) taskDone() {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	r.running--
+	if r.running == 0 {
+		close(r.idle)
+	}
+}
+
+// recorderOf returns the recorder that handles the records or nil if not recording.
+func recorderOf(h slog.Handler) *recorder {
+	switch r := h.(type) {
+	case *recorder:
+		return r
+	case subRecorder:
+		return r.root
+	}
+	return nil
+}
+
+// waitForTasks waits for the goroutines started by Go or GroupGo to finish, at most taskWait.
+func (r *recorder) waitForTasks() {
+	r.mux.RLock()
+	running, idle := r.running, r.idle
+	r.mux.RUnlock()
+	if running == 0 {
+		return
+	}
+	timer := time.NewTimer(r.taskWait)
+	defer timer.Stop()
+	select {
+	case <-idle:
+	case <-timer.C:
+	}
+}
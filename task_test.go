@@ -0,0 +1,111 @@
+package recall
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+// group mimics errgroup.Group
+type group struct {
+	wg  sync.WaitGroup
+	err error
+	mu  sync.Mutex
+}
+
+func (g *group) Go(f func() error) {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		if err := f(); err != nil {
+			g.mu.Lock()
+			g.err = err
+			g.mu.Unlock()
+		}
+	}()
+}
+
+func (g *group) Wait() error {
+	g.wg.Wait()
+	return g.err
+}
+
+func TestGoWaitsBeforeFlush(t *testing.T) {
+	sink := new(MemorySink)
+	r := New(context.Background()).WithCaptureStrategy(RecordingStrategy).WithSink(sink)
+	r.Call(func(ctx context.Context) error {
+		Go(ctx, func(ctx context.Context) {
+			time.Sleep(10 * time.Millisecond)
+			Slog(ctx).Debug("child")
+		})
+		return errors.New("fail")
+	})
+	records := sink.Sessions()[0].Records
+	if got, want := len(records), 1; got != want {
+		t.Fatalf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	attrs := attrsFrom(records[0])
+	if got, want := attrs[0].Key, TaskKey; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+	if got, want := attrs[0].Value.Int64(), int64(1); got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
+
+func TestGoWaitTimeout(t *testing.T) {
+	sink := new(MemorySink)
+	r := New(context.Background()).WithCaptureStrategy(RecordingStrategy).WithSink(sink).WithTaskWait(time.Millisecond)
+	release := make(chan struct{})
+	defer close(release)
+	before := runtime.NumGoroutine()
+	start := time.Now()
+	r.Call(func(ctx context.Context) error {
+		Go(ctx, func(ctx context.Context) {
+			<-release
+		})
+		return errors.New("fail")
+	})
+	if time.Since(start) > time.Second {
+		t.Error("expected flush without waiting for the task")
+	}
+	// only the task itself is still running
+	if got, want := runtime.NumGoroutine(), before+1; got > want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
+
+func TestGroupGo(t *testing.T) {
+	sink := new(MemorySink)
+	r := New(context.Background()).WithCaptureStrategy(RecordingStrategy).WithSink(sink)
+	r.Call(func(ctx context.Context) error {
+		g := new(group)
+		for range 3 {
+			GroupGo(g, ctx, func(ctx context.Context) error {
+				Slog(ctx).Debug("member")
+				return errors.New("member failed")
+			})
+		}
+		return g.Wait()
+	})
+	tasks := map[int64]bool{}
+	for _, each := range sink.Sessions()[0].Records {
+		tasks[attrsFrom(each)[0].Value.Int64()] = true
+	}
+	if got, want := len(tasks), 3; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
+
+func TestGoWithoutRecording(t *testing.T) {
+	done := make(chan context.Context)
+	ctx := ContextWithLogger(context.Background(), slog.Default())
+	Go(ctx, func(ctx context.Context) { done <- ctx })
+	if got, want := <-done, ctx; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}